@@ -1,5 +1,10 @@
 package litexexecutor
 
+import (
+	"fmt"
+	parser "golitex/litex_parser"
+)
+
 type ExecStatus uint8
 
 const (
@@ -8,6 +13,52 @@ const (
 )
 
 type ExecValue struct {
-	status  ExecStatus
-	message string
+	status      ExecStatus
+	message     string
+	diagnostics []parser.Diagnostic // warnings, e.g. a known fact which is already known
+	span        parser.Span         // span of the statement which is executed
+}
+
+// Warnings returns the warnings as text, each followed by the notes of its related spans.
+func (v *ExecValue) Warnings() []string {
+	ret := []string{}
+	for i := range v.diagnostics {
+		d := &v.diagnostics[i]
+		if d.Severity != parser.SeverityWarning {
+			continue
+		}
+		warning := d.Error()
+		for _, related := range d.Related {
+			warning += fmt.Sprintf("\n%s: %s", related.Span, related.Note)
+		}
+		ret = append(ret, warning)
+	}
+	return ret
+}
+
+// Diagnostics returns the warnings as diagnostics.
+func (v *ExecValue) Diagnostics() []parser.Diagnostic {
+	return v.diagnostics
+}
+
+func (v *ExecValue) Span() parser.Span {
+	return v.span
+}
+
+// ExecErr is an error raised when executing the statement at Span.
+type ExecErr struct {
+	previous error
+	Span     parser.Span
+}
+
+func (e *ExecErr) Error() string {
+	return fmt.Sprintf("%s: %s", e.Span, e.previous.Error())
+}
+
+func (e *ExecErr) Unwrap() error {
+	return e.previous
+}
+
+func (e *ExecErr) Diagnostic() parser.Diagnostic {
+	return parser.Diagnostic{Code: parser.DiagExecError, Severity: parser.SeverityError, Message: e.previous.Error(), Span: e.Span}
 }
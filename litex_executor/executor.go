@@ -2,21 +2,66 @@ package litexexecutor
 
 import (
 	"fmt"
+	memory "golitex/litex_memory"
 	parser "golitex/litex_parser"
 	env "golitex/litex_runtime_environment"
 )
 
 func ExecTopLevelStmt(env *env.Env, stmt *parser.TopStmt) (*ExecValue, error) {
-	return execStmt(env, &stmt.Stmt)
+	return execStmt(env, &stmt.Stmt, stmt)
 }
 
-func execStmt(env *env.Env, stmt *parser.Stmt) (*ExecValue, error) {
+// newFactProvenance records that facts of the given origin come from the top-level statement top.
+func newFactProvenance(origin memory.FactOrigin, top *parser.TopStmt) memory.FactProvenance {
+	return memory.FactProvenance{Origin: origin, File: top.File, Line: top.Line, TopStmtIndex: top.Index}
+}
+
+// top is the top-level statement which contains stmt
+func execStmt(env *env.Env, stmt *parser.Stmt, top *parser.TopStmt) (*ExecValue, error) {
+	ret, err := execStmtWithoutSpan(env, stmt, top)
+	if err != nil {
+		if _, ok := err.(*ExecErr); ok {
+			return nil, err
+		}
+		return nil, &ExecErr{err, (*stmt).GetSpan()}
+	}
+	ret.span = (*stmt).GetSpan()
+	return ret, nil
+}
+
+func execStmtWithoutSpan(env *env.Env, stmt *parser.Stmt, top *parser.TopStmt) (*ExecValue, error) {
 	switch (*stmt).(type) {
 	case *parser.DefVarStmt:
 		return execDefVarStmt(env, (*stmt).(*parser.DefVarStmt))
+	case *parser.DefPropStmt:
+		return execDefPropStmt(env, (*stmt).(*parser.DefPropStmt), top)
+	case *parser.DefExistStmt:
+		return execDefExistStmt(env, (*stmt).(*parser.DefExistStmt))
+	case *parser.DefFnStmt:
+		return execDefFnStmt(env, (*stmt).(*parser.DefFnStmt))
+	case *parser.DefAliasStmt:
+		return execDefAliasStmt(env, (*stmt).(*parser.DefAliasStmt))
+	case *parser.DefTypeStmt:
+		return execDefTypeStmt(env, (*stmt).(*parser.DefTypeStmt))
+	case *parser.KnowStmt:
+		return execKnowStmt(env, (*stmt).(*parser.KnowStmt), top)
+	case *parser.DefNotationStmt:
+		return execDefNotationStmt(env, (*stmt).(*parser.DefNotationStmt))
+	case *parser.AxiomStmt:
+		return execAxiomStmt(env, (*stmt).(*parser.AxiomStmt), top)
+	case *parser.ThmStmt:
+		return execThmStmt(env, (*stmt).(*parser.ThmStmt), top)
+	case *parser.ClaimProveStmt:
+		claim := (*stmt).(*parser.ClaimProveStmt)
+		return execClaimStmt(env, claim.ToCheck, claim.Proof, top)
+	case *parser.ClaimProveByContradictStmt:
+		claim := (*stmt).(*parser.ClaimProveByContradictStmt)
+		return execClaimStmt(env, claim.ToCheck, claim.Proof, top)
+	case *parser.HaveStmt:
+		return execHaveStmt(env, (*stmt).(*parser.HaveStmt), top)
 	}
 
-	return nil, fmt.Errorf("unknown statement type: %T", stmt)
+	return nil, fmt.Errorf("unknown statement type: %T", *stmt)
 }
 
 func execDefVarStmt(env *env.Env, stmt *parser.DefVarStmt) (*ExecValue, error) {
@@ -24,5 +69,278 @@ func execDefVarStmt(env *env.Env, stmt *parser.DefVarStmt) (*ExecValue, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &ExecValue{ExecTrue, ""}, nil
+	return &ExecValue{status: ExecTrue}, nil
+}
+
+// the then facts of a prop are known whenever the prop holds, by a universal fact of origin def
+func execDefPropStmt(env *env.Env, stmt *parser.DefPropStmt, top *parser.TopStmt) (*ExecValue, error) {
+	err := env.NewProp(&stmt.Decl)
+	if err != nil {
+		return nil, err
+	}
+
+	unfolding := propUnfolding(stmt)
+	if unfolding == nil {
+		return &ExecValue{status: ExecTrue}, nil
+	}
+	return knowFacts(env, []parser.FactStmt{unfolding}, newFactProvenance(memory.FactOriginDefUnfolding, top))
+}
+
+func execDefExistStmt(env *env.Env, stmt *parser.DefExistStmt) (*ExecValue, error) {
+	err := env.NewExistProp(stmt)
+	if err != nil {
+		return nil, err
+	}
+	return &ExecValue{status: ExecTrue}, nil
+}
+
+func execDefFnStmt(env *env.Env, stmt *parser.DefFnStmt) (*ExecValue, error) {
+	err := env.NewFn(&parser.FcFnDecl{Name: stmt.Name, Tp: stmt.Tp})
+	if err != nil {
+		return nil, err
+	}
+	return &ExecValue{status: ExecTrue}, nil
+}
+
+func execDefAliasStmt(env *env.Env, stmt *parser.DefAliasStmt) (*ExecValue, error) {
+	err := env.NewAlias(stmt)
+	if err != nil {
+		return nil, err
+	}
+	return &ExecValue{status: ExecTrue}, nil
+}
+
+func execDefTypeStmt(env *env.Env, stmt *parser.DefTypeStmt) (*ExecValue, error) {
+	err := env.NewType(stmt)
+	if err != nil {
+		return nil, err
+	}
+	return &ExecValue{status: ExecTrue}, nil
+}
+
+// notations are declared while parsing; executing one checks that what it stands for exists
+func execDefNotationStmt(env *env.Env, stmt *parser.DefNotationStmt) (*ExecValue, error) {
+	if stmt.IsProp {
+		if _, ok := env.GetProp(stmt.Binding); !ok {
+			return nil, fmt.Errorf("notation %s stands for undeclared prop %s", stmt.Symbol, stmt.Binding)
+		}
+	} else if _, ok := env.GetFn(stmt.Binding); !ok {
+		return nil, fmt.Errorf("notation %s stands for undeclared fn %s", stmt.Symbol, stmt.Binding)
+	}
+	return &ExecValue{status: ExecTrue}, nil
+}
+
+func execKnowStmt(env *env.Env, stmt *parser.KnowStmt, top *parser.TopStmt) (*ExecValue, error) {
+	return knowFacts(env, stmt.Facts, newFactProvenance(memory.FactOriginKnow, top))
+}
+
+// an axiom declares a prop and knows that it holds for all its parameters which satisfy its conds
+func execAxiomStmt(env *env.Env, stmt *parser.AxiomStmt, top *parser.TopStmt) (*ExecValue, error) {
+	return execPropHolds(env, stmt.Decl, memory.FactOriginAxiom, top)
+}
+
+// Proofs are executed in a child env, which is dropped afterwards. What they prove is not verified yet.
+func execThmStmt(curEnv *env.Env, stmt *parser.ThmStmt, top *parser.TopStmt) (*ExecValue, error) {
+	if err := execProof(curEnv, stmt.Proof, top); err != nil {
+		return nil, err
+	}
+	return execPropHolds(curEnv, stmt.Decl, memory.FactOriginThm, top)
+}
+
+func execClaimStmt(curEnv *env.Env, toCheck []parser.FactStmt, proof []parser.Stmt, top *parser.TopStmt) (*ExecValue, error) {
+	if err := execProof(curEnv, proof, top); err != nil {
+		return nil, err
+	}
+	return knowFacts(curEnv, toCheck, newFactProvenance(memory.FactOriginClaim, top))
+}
+
+func execProof(curEnv *env.Env, proof []parser.Stmt, top *parser.TopStmt) error {
+	proofEnv := env.NewChildEnv(curEnv)
+	for i := range proof {
+		if _, err := execStmt(proofEnv, &proof[i], top); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func execPropHolds(curEnv *env.Env, decl parser.DefPropExistDeclStmt, origin memory.FactOrigin, top *parser.TopStmt) (*ExecValue, error) {
+	var ret *ExecValue
+	var err error
+	var propDecl *parser.PropDecl
+	var ifFacts []parser.FactStmt
+
+	switch decl := decl.(type) {
+	case *parser.DefPropStmt:
+		ret, err = execDefPropStmt(curEnv, decl, top)
+		propDecl, ifFacts = &decl.Decl, decl.IfFacts
+	case *parser.DefExistStmt:
+		ret, err = execDefExistStmt(curEnv, decl)
+		propDecl, ifFacts = &decl.Decl, decl.IfFacts
+	default:
+		return nil, fmt.Errorf("unknown prop declaration type: %T", decl)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	holds, err := knowFacts(curEnv, []parser.FactStmt{propHoldsFact(propDecl, ifFacts, nil)}, newFactProvenance(origin, top))
+	if err != nil {
+		return nil, err
+	}
+	holds.diagnostics = append(ret.diagnostics, holds.diagnostics...)
+	return holds, nil
+}
+
+// have $e(a): m gives the members of the exist prop e the names m, and knows the then facts of e about them
+func execHaveStmt(curEnv *env.Env, stmt *parser.HaveStmt, top *parser.TopStmt) (*ExecValue, error) {
+	fact, ok := stmt.PropStmt.(*parser.FuncFactStmt)
+	if !ok || !fact.IsTrue {
+		return nil, fmt.Errorf("%s is not an exist prop which holds", stmt.PropStmt)
+	}
+	call, ok := fact.Fc.(*parser.FcFnRetValue)
+	if !ok || len(call.TypeParamsVarParamsPairs) != 1 {
+		return nil, fmt.Errorf("%s is not an exist prop which holds", stmt.PropStmt)
+	}
+	prop, ok := curEnv.GetProp(call.FnName.Value)
+	if !ok || prop.Exist == nil {
+		return nil, fmt.Errorf("%s is not an exist prop", call.FnName)
+	}
+	exist := prop.Exist
+
+	known, err := curEnv.IsSpecFactKnown(fact)
+	if err != nil {
+		return nil, err
+	}
+	if !known {
+		return nil, fmt.Errorf("%s is not known", fact)
+	}
+
+	args := call.TypeParamsVarParamsPairs[0].VarParams
+	if len(args) != len(exist.Decl.Tp.VarParams) {
+		return nil, fmt.Errorf("%s takes %d parameters, but is given %d", exist.Decl.Name, len(exist.Decl.Tp.VarParams), len(args))
+	}
+	if len(stmt.Member) != len(exist.Member) {
+		return nil, fmt.Errorf("%s has %d members, but %d names are given", exist.Decl.Name, len(exist.Member), len(stmt.Member))
+	}
+
+	subst := map[string]parser.Fc{}
+	for i, param := range exist.Decl.Tp.VarParams {
+		subst[param.Var] = args[i]
+	}
+	for i, member := range exist.Member {
+		name := stmt.Member[i]
+		var err error
+		switch member := member.(type) {
+		case *parser.FcVarDecl:
+			subst[member.VarTypePair.Var] = parser.FcStr{Value: name}
+			err = curEnv.NewVar(&parser.FcVarDeclPair{Var: name, Tp: member.VarTypePair.Tp})
+		case *parser.FcFnDecl:
+			subst[member.Name] = parser.FcStr{Value: name}
+			err = curEnv.NewFn(&parser.FcFnDecl{Name: name, Tp: member.Tp})
+		case *parser.PropDecl:
+			subst[member.Name] = parser.FcStr{Value: name}
+			err = curEnv.NewProp(&parser.PropDecl{Name: name, Tp: member.Tp})
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	facts := []parser.FactStmt{}
+	for _, thenFact := range exist.ThenFacts {
+		cur, err := parser.SubstituteFact(thenFact, subst)
+		if err != nil {
+			return nil, err
+		}
+		facts = append(facts, cur)
+	}
+	return knowFacts(curEnv, facts, newFactProvenance(memory.FactOriginHave, top))
+}
+
+func knowFacts(env *env.Env, facts []parser.FactStmt, provenance memory.FactProvenance) (*ExecValue, error) {
+	diagnostics := []parser.Diagnostic{}
+	for _, fact := range facts {
+		redundancies, err := env.NewFact(fact, provenance)
+		if err != nil {
+			return nil, err
+		}
+		diagnostics = append(diagnostics, redundancyDiagnostics(fact, redundancies)...)
+	}
+	return &ExecValue{status: ExecTrue, diagnostics: diagnostics}, nil
+}
+
+// propUnfolding returns the universal fact that the then facts of stmt hold whenever its prop does, or
+// nil if it has none. Then facts which are not spec facts are left out, since a universal fact only
+// concludes spec facts.
+func propUnfolding(stmt *parser.DefPropStmt) parser.FactStmt {
+	then := []parser.SpecFactStmt{}
+	for _, fact := range stmt.ThenFacts {
+		if fact, ok := fact.(parser.SpecFactStmt); ok {
+			then = append(then, fact)
+		}
+	}
+	if len(then) == 0 {
+		return nil
+	}
+
+	cond := append(append([]parser.FactStmt{}, stmt.IfFacts...), propCall(&stmt.Decl))
+	return propHoldsFact(&stmt.Decl, cond, then)
+}
+
+// propHoldsFact returns the fact that then holds for all parameters of decl which satisfy cond, or
+// that the prop of decl holds if then is nil. cond is not empty if then has more than one fact.
+func propHoldsFact(decl *parser.PropDecl, cond []parser.FactStmt, then []parser.SpecFactStmt) parser.FactStmt {
+	if then == nil {
+		then = []parser.SpecFactStmt{propCall(decl)}
+	}
+	if len(decl.Tp.TypeParams) > 0 || len(decl.Tp.VarParams) > 0 {
+		return &parser.BlockForallStmt{TypeParams: decl.Tp.TypeParams, VarParams: decl.Tp.VarParams, Cond: cond, Then: then}
+	}
+	if len(cond) > 0 {
+		return &parser.IfFactStmt{CondFacts: cond, ThenFacts: then}
+	}
+	return then[0]
+}
+
+// propCall returns the fact $p[T](x) that the prop of decl holds for its parameters.
+func propCall(decl *parser.PropDecl) *parser.FuncFactStmt {
+	typeParams := []parser.TypeVarStr{}
+	for _, param := range decl.Tp.TypeParams {
+		typeParams = append(typeParams, param.Var)
+	}
+	params := []parser.Fc{}
+	for _, param := range decl.Tp.VarParams {
+		params = append(params, parser.FcStr{Value: param.Var})
+	}
+	call := &parser.FcFnRetValue{FnName: parser.FcStr{Value: decl.Name}, TypeParamsVarParamsPairs: []parser.TypeParamsAndParamsPair{{TypeParams: typeParams, VarParams: params}}}
+	return &parser.FuncFactStmt{IsTrue: true, Fc: call}
+}
+
+func redundancyDiagnostics(fact parser.FactStmt, redundancies []memory.FactRedundancy) []parser.Diagnostic {
+	ret := []parser.Diagnostic{}
+	for _, redundancy := range redundancies {
+		diagnostic := parser.Diagnostic{Severity: parser.SeverityWarning, Span: fact.GetSpan()}
+		// provenances only know the line of the older fact
+		older := parser.Span{File: redundancy.Provenance.File, Start: parser.Position{Line: redundancy.Provenance.Line, Column: 1}}
+		older.End = older.Start
+
+		switch redundancy.Kind {
+		case memory.FactDuplicate:
+			diagnostic.Code = parser.DiagDuplicateFact
+			diagnostic.Message = fmt.Sprintf("%s is already known", fact)
+			diagnostic.Related = []parser.RelatedSpan{{Span: older, Note: fmt.Sprintf("known by %s", redundancy.Provenance.Origin)}}
+			diagnostic.Fix = &parser.SuggestedFix{Message: "remove the fact", Span: fact.GetSpan()}
+		case memory.FactImpliedByUniFact:
+			diagnostic.Code = parser.DiagImpliedFact
+			diagnostic.Message = fmt.Sprintf("%s is implied by a known universal fact", fact)
+			diagnostic.Related = []parser.RelatedSpan{{Span: older, Note: fmt.Sprintf("implied by %s", redundancy.Fact)}}
+		case memory.FactSubsumesOlder:
+			diagnostic.Code = parser.DiagSubsumingFact
+			diagnostic.Message = fmt.Sprintf("%s makes a known fact redundant", fact)
+			diagnostic.Related = []parser.RelatedSpan{{Span: older, Note: fmt.Sprintf("%s is now redundant", redundancy.Fact)}}
+		}
+		ret = append(ret, diagnostic)
+	}
+	return ret
 }
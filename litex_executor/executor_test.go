@@ -2,8 +2,13 @@ package litexexecutor
 
 import (
 	"fmt"
+	memory "golitex/litex_memory"
 	parser "golitex/litex_parser"
 	env "golitex/litex_runtime_environment"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -25,3 +30,851 @@ func TestStoreNewVar(t *testing.T) {
 	entry, _ := env.VarMemory.Get("a")
 	println(string(entry.Tp.Value.(parser.FcVarTypeStrValue)))
 }
+
+func TestVarFnPropNamesDoNotConflict(t *testing.T) {
+	code := `
+var a G
+prop p(x G)
+fn f(x G) G
+alias p q
+`
+	statements, err := parser.ParseSourceCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent := env.NewEnv()
+	for _, topStmt := range *statements {
+		if _, err := ExecTopLevelStmt(parent, &topStmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, ok := parent.GetProp("p"); !ok {
+		t.Fatal("expected p to be a prop")
+	}
+	if _, ok := parent.GetFn("f"); !ok {
+		t.Fatal("expected f to be a fn")
+	}
+	if entry, ok := parent.GetAlias("q"); !ok || (*entry.Values)[0] != "p" {
+		t.Fatal("expected q to be an alias of p")
+	}
+
+	child := env.NewEnv()
+	child.Parent = parent
+
+	conflicts := []string{"var p G", "prop a(x G)", "fn q(x G) G", "var forall G"}
+	for _, code := range conflicts {
+		statements, err := parser.ParseSourceCode(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ExecTopLevelStmt(child, &(*statements)[0]); err == nil {
+			t.Fatalf("expected %q to conflict with names in the parent env", code)
+		} else {
+			fmt.Println(err)
+		}
+	}
+
+	if _, ok := child.GetVar("a"); !ok {
+		t.Fatal("expected a to be found in the parent env")
+	}
+
+	for code, known := range map[string]bool{"alias a b": true, "alias q c": true, "alias r s": false} {
+		statements, err := parser.ParseSourceCode(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ExecTopLevelStmt(child, &(*statements)[0]); (err == nil) != known {
+			t.Fatalf("%q: got error %v", code, err)
+		}
+	}
+}
+
+func TestSaveAndLoadEnv(t *testing.T) {
+	dir := t.TempDir()
+	sourceFile := filepath.Join(dir, "lib.litex")
+	code := `
+var a G
+var b G
+prop p(x G)
+fn f(x G) G
+alias p q
+exist e(x G):
+    then:
+        $p(m)
+    member:
+        var m G
+know:
+    $p(a)
+    not a < f(b)
+    if:
+        $p(a)
+        then:
+            $p(b)
+    forall x G:
+        cond:
+            $p(x)
+        then:
+            $p(f(x))
+            f(x) = f(f(x))
+`
+	if err := os.WriteFile(sourceFile, []byte(code), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	statements, err := parser.ParseSourceCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := env.NewEnv()
+	for _, topStmt := range *statements {
+		if _, err := ExecTopLevelStmt(saved, &topStmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	snapshotFile := filepath.Join(dir, "lib.snapshot")
+	if err := saved.Save(snapshotFile, []string{sourceFile}); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := env.Load(snapshotFile, []string{sourceFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resavedFile := filepath.Join(dir, "lib2.snapshot")
+	if err := loaded.Save(resavedFile, []string{sourceFile}); err != nil {
+		t.Fatal(err)
+	}
+	snapshot, _ := os.ReadFile(snapshotFile)
+	resaved, _ := os.ReadFile(resavedFile)
+	if string(snapshot) != string(resaved) {
+		t.Fatalf("loaded env differs from the saved one:\n%s\n%s", snapshot, resaved)
+	}
+
+	candidates, err := loaded.UniFactMemory.GetCandidates(&parser.FuncFactStmt{IsTrue: true, Fc: &parser.FcFnRetValue{FnName: parser.FcStr{Value: "p"}, TypeParamsVarParamsPairs: []parser.TypeParamsAndParamsPair{{TypeParams: []parser.TypeVarStr{}, VarParams: []parser.Fc{&parser.FcFnRetValue{FnName: parser.FcStr{Value: "f"}, TypeParamsVarParamsPairs: []parser.TypeParamsAndParamsPair{{TypeParams: []parser.TypeVarStr{}, VarParams: []parser.Fc{parser.FcStr{Value: "a"}}}}}}}}}})
+	if err != nil || len(candidates) != 1 {
+		t.Fatalf("expected the loaded universal fact to be indexed, got %d candidates, %v", len(candidates), err)
+	}
+
+	if prop, ok := loaded.GetProp("e"); !ok || prop.Exist == nil || len(prop.Exist.Member) != 1 {
+		t.Fatalf("expected the members of the exist prop e to be loaded, got %v", prop)
+	}
+
+	if err := os.WriteFile(sourceFile, []byte(code+"\nvar c G\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.Load(snapshotFile, []string{sourceFile}); err == nil {
+		t.Fatal("expected a stale snapshot to be rejected")
+	} else {
+		fmt.Println(err)
+	}
+}
+
+func TestEnvIntrospection(t *testing.T) {
+	execCode := func(e *env.Env, code string) {
+		statements, err := parser.ParseSourceCode(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, topStmt := range *statements {
+			if _, err := ExecTopLevelStmt(e, &topStmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	parent := env.NewEnv()
+	execCode(parent, `
+var a G
+var b G
+prop p(x G)
+fn f(x G) G
+alias p q
+know:
+    $p(a)
+    a < b
+    forall x G:
+        cond:
+            $p(x)
+        then:
+            $p(f(x))
+`)
+
+	child := env.NewEnv()
+	child.Parent = parent
+	execCode(child, `
+var c G
+know:
+    $p(f(b))
+    if:
+        $p(b)
+        then:
+            $p(c)
+`)
+
+	count := child.FactCount()
+	if count != (env.FactCount{Vars: 1, SpecFacts: 1, CondFacts: 1}) {
+		t.Fatalf("unexpected fact count of the child env: %+v", count)
+	}
+
+	factsOfP, err := child.FactsOfProp("p")
+	if err != nil || len(factsOfP) != 4 {
+		t.Fatalf("expected 4 facts about p, got %v, %v", factsOfP, err)
+	}
+
+	factsAboutB, err := child.FactsAbout(parser.FcStr{Value: "b"})
+	if err != nil || len(factsAboutB) != 3 {
+		t.Fatalf("expected 3 facts about b, got %v, %v", factsAboutB, err)
+	}
+
+	// x is bound by the forall, so it is not the x of the env
+	factsAboutX, err := child.FactsAbout(parser.FcStr{Value: "x"})
+	if err != nil || len(factsAboutX) != 0 {
+		t.Fatalf("expected no fact about x, got %v, %v", factsAboutX, err)
+	}
+
+	dump, err := child.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(dump)
+
+	redone := env.NewEnv()
+	execCode(redone, dump)
+	if count := redone.FactCount(); count != (env.FactCount{Vars: 3, Props: 1, Fns: 1, Aliases: 1, SpecFacts: 3, CondFacts: 1, UniFacts: 1}) {
+		t.Fatalf("unexpected fact count after executing the dump: %+v", count)
+	}
+
+	redump, err := redone.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	redoneTwice := env.NewEnv()
+	execCode(redoneTwice, redump)
+	if again, _ := redoneTwice.Dump(); again != redump {
+		t.Fatalf("executing the dump of an env without parents gives another env:\n%s\n%s", redump, again)
+	}
+}
+
+func TestFactProvenance(t *testing.T) {
+	sourceFile := filepath.Join(t.TempDir(), "lib.litex")
+	code := `var a G
+prop p(x G)
+
+know:
+    $p(a)
+
+// the same fact again
+know:
+    $p(a)
+    if:
+        $p(a)
+        then:
+            a = a
+    forall x G:
+        $p(x)
+`
+	if err := os.WriteFile(sourceFile, []byte(code), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	statements, err := parser.ParseSourceFile(sourceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := env.NewEnv()
+	for _, topStmt := range *statements {
+		if _, err := ExecTopLevelStmt(e, &topStmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pOfA := &parser.FuncFactStmt{IsTrue: true, Fc: &parser.FcFnRetValue{FnName: parser.FcStr{Value: "p"}, TypeParamsVarParamsPairs: []parser.TypeParamsAndParamsPair{{TypeParams: []parser.TypeVarStr{}, VarParams: []parser.Fc{parser.FcStr{Value: "a"}}}}}}
+
+	provenance, ok, err := e.SpecFactMemory.GetProvenance(pOfA)
+	if err != nil || !ok {
+		t.Fatalf("expected $p(a) to be known, %v", err)
+	}
+	if *provenance != (memory.FactProvenance{Origin: memory.FactOriginKnow, File: sourceFile, Line: 4, TopStmtIndex: 2}) {
+		t.Fatalf("expected $p(a) to come from the first know, got %v", provenance)
+	}
+
+	err = e.CondFactMemory.Traverse(func(fact *parser.IfFactStmt, provenance memory.FactProvenance) error {
+		if provenance.Line != 8 || provenance.TopStmtIndex != 3 {
+			return fmt.Errorf("unexpected provenance of %s: %v", fact, provenance)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	candidates, err := e.UniFactMemory.GetCandidates(pOfA)
+	if err != nil || len(candidates) != 1 {
+		t.Fatalf("expected one candidate, got %d, %v", len(candidates), err)
+	}
+	fmt.Println(candidates[0].Fact.Provenance())
+	if candidates[0].Fact.Provenance().Line != 8 {
+		t.Fatalf("unexpected provenance of the forall fact: %v", candidates[0].Fact.Provenance())
+	}
+}
+
+func TestFactOrigins(t *testing.T) {
+	code := `var a G
+prop p(x G)
+prop q(x G):
+    $p(x)
+axiom prop r(x G):
+    $p(x)
+thm:
+    prop s(x G)
+    prove:
+        know $q(a)
+claim:
+    $p(a)
+    prove:
+        know $p(a)
+exist e(x G):
+    then:
+        $p(m)
+    member:
+        var m G
+know $e(a)
+have $e(a):
+    b
+`
+	statements, err := parser.ParseSourceCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := env.NewEnv()
+	for _, topStmt := range *statements {
+		if _, err := ExecTopLevelStmt(e, &topStmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	origins := map[string]memory.FactOrigin{}
+	err = e.SpecFactMemory.Traverse(func(fact parser.SpecFactStmt, provenance memory.FactProvenance) error {
+		origins[fact.String()] = provenance.Origin
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.UniFactMemory.Traverse(func(fact *parser.BlockForallStmt, provenance memory.FactProvenance) error {
+		origins[fact.String()] = provenance.Origin
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]memory.FactOrigin{
+		"forall x G:\n    cond:\n        $q(x)\n    then:\n        $p(x)": memory.FactOriginDefUnfolding,
+		"forall x G:\n    cond:\n        $r(x)\n    then:\n        $p(x)": memory.FactOriginDefUnfolding,
+		"forall x G:\n    $r(x)": memory.FactOriginAxiom,
+		"forall x G:\n    $s(x)": memory.FactOriginThm,
+		"$p(a)":                  memory.FactOriginClaim,
+		"$e(a)":                  memory.FactOriginKnow,
+		"$p(b)":                  memory.FactOriginHave,
+	}
+	if len(origins) != len(expected) {
+		t.Fatalf("expected %d facts, got %v", len(expected), origins)
+	}
+	for fact, origin := range expected {
+		if cur, ok := origins[fact]; !ok || cur != origin {
+			t.Fatalf("expected %s to be known by %s, got %v", fact, origin, origins)
+		}
+	}
+
+	// the fact which a proof knows stays in the env of the proof
+	if known, err := e.IsSpecFactKnown(&parser.FuncFactStmt{IsTrue: true, Fc: &parser.FcFnRetValue{FnName: parser.FcStr{Value: "q"}, TypeParamsVarParamsPairs: []parser.TypeParamsAndParamsPair{{TypeParams: []parser.TypeVarStr{}, VarParams: []parser.Fc{parser.FcStr{Value: "a"}}}}}}); err != nil || known {
+		t.Fatalf("expected $q(a) not to be known, %v", err)
+	}
+	if _, ok := e.GetVar("b"); !ok {
+		t.Fatal("expected have to declare b")
+	}
+
+	// have needs the exist prop to be known
+	statements, err = parser.ParseSourceCode("var c G\nhave $e(c):\n    d\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExecTopLevelStmt(e, &(*statements)[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ExecTopLevelStmt(e, &(*statements)[1]); err == nil || !strings.Contains(err.Error(), "$e(c) is not known") {
+		t.Fatalf("expected have to fail, got %v", err)
+	}
+}
+
+func TestRedundantFacts(t *testing.T) {
+	code := `
+var a G
+var b G
+prop p(x G)
+prop q(x G)
+know:
+    if:
+        $p(b)
+        then:
+            $p(a)
+know:
+    $p(a)
+    $p(b)
+    $q(b)
+know:
+    $p(a)
+know:
+    forall x G:
+        cond:
+            $p(x)
+        then:
+            $q(x)
+know:
+    $q(a)
+know:
+    forall y G:
+        cond:
+            $p(y)
+        then:
+            $q(y)
+`
+	statements, err := parser.ParseSourceCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// $p(a) makes the if fact redundant, the second $p(a) is a duplicate, the first forall makes $q(b) redundant,
+	// $q(a) follows from the first forall and so does the second forall
+	expectedWarnings := []int{0, 0, 0, 0, 0, 1, 1, 1, 1, 1}
+	e := env.NewEnv()
+	for i, topStmt := range *statements {
+		value, err := ExecTopLevelStmt(e, &topStmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, warning := range value.Warnings() {
+			fmt.Println(warning)
+		}
+		if len(value.Warnings()) != expectedWarnings[i] {
+			t.Fatalf("expected %d warnings for statement %d, got %v", expectedWarnings[i], i, value.Warnings())
+		}
+	}
+
+	redundancies, err := e.CheckNewFact((*statements)[6].Stmt.(*parser.KnowStmt).Facts[0])
+	if err != nil || len(redundancies) != 1 || redundancies[0].Kind != memory.FactDuplicate || redundancies[0].Provenance.TopStmtIndex != 5 {
+		t.Fatalf("expected $p(a) to duplicate the fact of statement 5, got %v, %v", redundancies, err)
+	}
+
+	count := e.FactCount().SpecFacts
+	e.SkipDuplicateFacts = true
+	if _, err := ExecTopLevelStmt(e, &(*statements)[6]); err != nil {
+		t.Fatal(err)
+	}
+	if e.FactCount().SpecFacts != count {
+		t.Fatal("expected a duplicate not to be stored")
+	}
+
+	e.SkipDuplicateFacts = false
+	if _, err := ExecTopLevelStmt(e, &(*statements)[6]); err != nil {
+		t.Fatal(err)
+	}
+	if e.FactCount().SpecFacts != count+1 {
+		t.Fatal("expected a duplicate to be stored")
+	}
+
+	// the x which the last forall binds is not the var x, so $p(x) does not make the forall implied
+	statements, err = parser.ParseSourceCode(`var x G
+prop p(x G)
+prop q(x G)
+know:
+    forall y G:
+        cond:
+            $p(y)
+        then:
+            $q(y)
+    $p(x)
+know:
+    forall x G:
+        $q(x)
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e = env.NewEnv()
+	for _, topStmt := range *statements {
+		value, err := ExecTopLevelStmt(e, &topStmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(value.Warnings()) != 0 {
+			t.Fatalf("expected no warnings, got %v", value.Warnings())
+		}
+	}
+}
+
+func TestVarTypes(t *testing.T) {
+	execCode := func(e *env.Env, code string) {
+		statements, err := parser.ParseSourceCode(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, topStmt := range *statements {
+			if _, err := ExecTopLevelStmt(e, &topStmt); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	varType := func(name string) parser.FcVarType {
+		return parser.FcVarType{PackageName: "", Value: parser.FcVarTypeStrValue(name)}
+	}
+
+	parent := env.NewEnv()
+	execCode(parent, `
+type Real
+type impl Real Nat
+type Int
+prop p(x G)
+var one Real
+var x G
+know:
+    one is Nat
+    one is Nat
+    x is Int
+    x is p
+`)
+
+	if entry, _ := parent.GetVar("one"); len(entry.Types) != 2 {
+		t.Fatalf("expected one to have 2 types, got %v", entry.Types)
+	}
+	if !parent.HasType("one", varType("Real")) || !parent.HasType("one", varType("Nat")) {
+		t.Fatal("expected one to be both Real and Nat")
+	}
+	if !parent.HasType("x", varType("Int")) || parent.HasType("x", varType("Real")) {
+		t.Fatal("expected x to be Int and not Real")
+	}
+	if vars := parent.GetVarsOfType(varType("Nat")); len(vars) != 1 || vars[0] != "one" {
+		t.Fatalf("expected one to be the only Nat, got %v", vars)
+	}
+	// a fact giving a var a type is known as a spec fact as well, like x is p
+	if count := parent.FactCount(); count.SpecFacts != 4 {
+		t.Fatalf("expected every is fact to be a spec fact, got %+v", count)
+	}
+	isNat := parser.FuncFactStmt{IsTrue: true, Fc: &parser.FcFnRetValue{FnName: parser.FcStr{Value: "Nat"}, TypeParamsVarParamsPairs: []parser.TypeParamsAndParamsPair{{TypeParams: []parser.TypeVarStr{}, VarParams: []parser.Fc{parser.FcStr{Value: "one"}}}}}}
+	if redundancies, err := parent.CheckNewFact(&isNat); err != nil || len(redundancies) != 1 || redundancies[0].Kind != memory.FactDuplicate {
+		t.Fatalf("expected one is Nat to be a known fact, got %v, %v", redundancies, err)
+	}
+
+	child := env.NewEnv()
+	child.Parent = parent
+	execCode(child, `know x is Nat`)
+
+	if !child.HasType("x", varType("Real")) {
+		t.Fatal("expected x to be Real in the child env, because Nat extends Real")
+	}
+	if parent.HasType("x", varType("Nat")) {
+		t.Fatal("expected the type given in the child env not to be known in the parent env")
+	}
+
+	dump, err := child.Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+	redone := env.NewEnv()
+	execCode(redone, dump)
+	if !redone.HasType("x", varType("Real")) || !redone.HasType("one", varType("Nat")) {
+		t.Fatalf("expected the types of vars to survive the dump:\n%s", dump)
+	}
+
+	dir := t.TempDir()
+	snapshotFile := filepath.Join(dir, "lib.snapshot")
+	if err := parent.Save(snapshotFile, []string{}); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := env.Load(snapshotFile, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.HasType("one", varType("Real")) || !loaded.HasType("one", varType("Nat")) || loaded.HasType("x", varType("Nat")) {
+		t.Fatal("expected the types of vars to survive the snapshot")
+	}
+	if vars := loaded.GetVarsOfType(varType("Int")); len(vars) != 1 || vars[0] != "x" {
+		t.Fatalf("expected the reverse index to survive the snapshot, got %v", vars)
+	}
+}
+
+// Run with go test -race: claims are verified in child envs of a shared parent while the parent gets new facts.
+func TestParallelVerificationAgainstSharedParent(t *testing.T) {
+	parse := func(code string) []parser.TopStmt {
+		statements, err := parser.ParseSourceCode(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return *statements
+	}
+
+	parent := env.NewEnv()
+	for _, topStmt := range parse(`
+type Real
+type impl Real Nat
+var a Nat
+prop p(x G)
+prop q(x G)
+know:
+    forall x G:
+        cond:
+            $p(x)
+        then:
+            $q(x)
+`) {
+		if _, err := ExecTopLevelStmt(parent, &topStmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	claims := parse(`
+know:
+    $p(a)
+    $q(a)
+`)
+	parentFacts := parse(`
+know:
+    $q(b)
+    $p(b)
+`)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 32)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			if _, err := ExecTopLevelStmt(parent, &parentFacts[0]); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			child := env.NewChildEnv(parent)
+			value, err := ExecTopLevelStmt(child, &claims[0])
+			if err != nil {
+				errs <- err
+				return
+			}
+			// $q(a) follows from the forall of the parent once $p(a) is known in the child
+			if len(value.Warnings()) != 1 {
+				errs <- fmt.Errorf("expected $q(a) to be implied, got %v", value.Warnings())
+				return
+			}
+			if !child.HasType("a", parser.FcVarType{PackageName: "", Value: parser.FcVarTypeStrValue("Real")}) {
+				errs <- fmt.Errorf("expected a to be Real")
+				return
+			}
+			if _, err := child.FactsAbout(parser.FcStr{Value: "b"}); err != nil {
+				errs <- err
+				return
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	if facts, _ := parent.FactsAbout(parser.FcStr{Value: "a"}); len(facts) != 0 {
+		t.Fatalf("expected the facts of the children not to be known in the parent, got %v", facts)
+	}
+}
+
+func TestExecErrPosition(t *testing.T) {
+	code := `
+var a G
+var a G
+`
+	statements, err := parser.ParseSourceCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := env.NewEnv()
+	value, err := ExecTopLevelStmt(env, &(*statements)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.Span().String() != "<source>:2:1" {
+		t.Fatalf("expected the value to be located at line 2, got %v", value.Span())
+	}
+
+	_, err = ExecTopLevelStmt(env, &(*statements)[1])
+	execErr, ok := err.(*ExecErr)
+	if !ok {
+		t.Fatalf("expected an ExecErr, got %v", err)
+	}
+	if execErr.Span.Start != (parser.Position{Line: 3, Column: 1}) || !strings.HasPrefix(err.Error(), "<source>:3:1: ") {
+		t.Fatalf("expected the error to be located at line 3, got %v", err)
+	}
+
+	// the error names the type of the statement which is not executed
+	statements, err = parser.ParseSourceCode("member [T C](x T) var m G\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ExecTopLevelStmt(env, &(*statements)[0]); err == nil || !strings.HasSuffix(err.Error(), "unknown statement type: *litexparser.DefMemberStmt") {
+		t.Fatalf("expected an unknown statement type, got %v", err)
+	}
+}
+
+func TestExecDiagnostics(t *testing.T) {
+	code := `
+know $p(a)
+know $p(a)
+var b G
+var b G
+`
+	statements, err := parser.ParseSourceCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := env.NewEnv()
+	values := []*ExecValue{}
+	for _, topStmt := range (*statements)[:3] {
+		value, err := ExecTopLevelStmt(env, &topStmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, value)
+	}
+
+	if len(values[0].Diagnostics()) != 0 {
+		t.Fatalf("expected no diagnostics for the first fact, got %v", values[0].Diagnostics())
+	}
+	diagnostics := values[1].Diagnostics()
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %v", diagnostics)
+	}
+	duplicate := diagnostics[0]
+	if duplicate.Code != parser.DiagDuplicateFact || duplicate.Severity != parser.SeverityWarning || duplicate.Span.Start.Line != 3 {
+		t.Fatalf("unexpected diagnostic: %+v", duplicate)
+	}
+	if len(duplicate.Related) != 1 || duplicate.Related[0].Span.Start.Line != 2 {
+		t.Fatalf("expected the diagnostic to point at the known fact, got %+v", duplicate.Related)
+	}
+
+	_, err = ExecTopLevelStmt(env, &(*statements)[3])
+	if diagnostics := parser.Diagnose(err); len(diagnostics) != 1 || diagnostics[0].Code != parser.DiagExecError || diagnostics[0].Span.Start.Line != 5 {
+		t.Fatalf("expected an execution error at line 5, got %v", diagnostics)
+	}
+}
+
+func TestOperatorAndDunderFactsMatch(t *testing.T) {
+	code := `
+type Real
+know 1 < 2
+know $Real.__lt__(1, 2)
+know $__add__(a, b) = c
+know a + b = c
+know not $x.__ne__(-y)
+know not x != -y
+know $x.__sub__(y) = z
+know x - y = z
+know $__pow__(a, b) = c
+know a ** b = c
+`
+	statements, err := parser.ParseSourceCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := env.NewEnv()
+	if _, err := ExecTopLevelStmt(env, &(*statements)[0]); err != nil {
+		t.Fatal(err)
+	}
+	for i, topStmt := range (*statements)[1:] {
+		value, err := ExecTopLevelStmt(env, &topStmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := i % 2
+		if len(value.Diagnostics()) != expected {
+			t.Fatalf("expected %d diagnostics for statement %d, got %v", expected, i, value.Diagnostics())
+		}
+		if expected == 1 && value.Diagnostics()[0].Code != parser.DiagDuplicateFact {
+			t.Fatalf("expected %s to be a duplicate, got %v", &topStmt, value.Diagnostics())
+		}
+	}
+}
+
+func TestDefNotation(t *testing.T) {
+	code := `
+fn compose(f G, g G) G
+notation infix ∘ fn compose
+know a ∘ b = c
+know compose(a, b) = c
+notation prefix √ fn sqrt
+`
+	statements, err := parser.ParseSourceCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := env.NewEnv()
+	for i, topStmt := range (*statements)[:4] {
+		value, err := ExecTopLevelStmt(env, &topStmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if i == 3 && (len(value.Diagnostics()) != 1 || value.Diagnostics()[0].Code != parser.DiagDuplicateFact) {
+			t.Fatalf("expected the fact written with notation to be known, got %v", value.Diagnostics())
+		}
+	}
+
+	if _, err := ExecTopLevelStmt(env, &(*statements)[4]); err == nil || !strings.Contains(err.Error(), "undeclared fn sqrt") {
+		t.Fatalf("expected an error for the undeclared fn, got %v", err)
+	}
+}
+
+func TestLambdaFacts(t *testing.T) {
+	code := `
+know:
+    forall P G, x R:
+        cond:
+            $P(x)
+        then:
+            $holds(P, x)
+know 1 < 2
+know $holds(prop(y R) => y < 2, 1)
+know $holds(prop(z R) ↦ z < 2, 1)
+know:
+    forall f G:
+        $continuous(f)
+know $continuous(fn(x R) => x ^ 2)
+`
+	statements, err := parser.ParseSourceCode(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the lambda is applied to 1 when the forall is instantiated, so the first $holds follows from 1 < 2 and the
+	// second, which differs only in the name of the parameter, is a duplicate
+	expected := []parser.DiagnosticCode{"", "", parser.DiagImpliedFact, parser.DiagDuplicateFact, "", parser.DiagImpliedFact}
+	e := env.NewEnv()
+	for i, topStmt := range *statements {
+		value, err := ExecTopLevelStmt(e, &topStmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diagnostics := value.Diagnostics()
+		if expected[i] == "" && len(diagnostics) != 0 || expected[i] != "" && (len(diagnostics) == 0 || diagnostics[0].Code != expected[i]) {
+			t.Fatalf("expected %q for %s, got %v", expected[i], &topStmt, diagnostics)
+		}
+	}
+}
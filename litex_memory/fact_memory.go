@@ -2,35 +2,51 @@ package litexmemory
 
 import (
 	parser "golitex/litex_parser"
+	"sync"
 )
 
 // Define type PropName to signify functionality of a string variable
 type PropName string
 
 type SpecFactMemory struct {
-	KnownFacts RedBlackTree
+	mu         sync.RWMutex
+	knownFacts RedBlackTree
 }
 
 type CondFactMemory struct {
-	KVs map[PropName]CondFactMemEntry
+	mu      sync.RWMutex
+	entries map[PropName]CondFactMemEntry
 }
 
 type CondFactMemEntry struct{ Facts []CondFactMemFact }
 
 type CondFactMemFact struct {
-	cond *[]parser.FactStmt
-	then parser.FactStmt
+	cond       *[]parser.FactStmt
+	then       parser.SpecFactStmt
+	provenance FactProvenance
 }
 
 type UniFactMemory struct {
-	Entires map[PropName]UniFactMemEntry
+	mu      sync.RWMutex
+	entries map[PropName]UniFactMemEntry
 }
 
-type UniFactMemEntry struct{ Facts []UniMemFact }
+type UniFactMemEntry struct {
+	Facts []UniMemFact
+	tree  *discTreeNode // indexes the shapes of then facts of Facts
+}
 
 type UniMemFact struct {
 	typeParams *[]parser.TypeConceptPair
 	varParams  *[]parser.StrTypePair
 	cond       *[]parser.FactStmt
 	then       *[]parser.SpecFactStmt
+	provenance FactProvenance
+}
+
+type UniFactCandidate struct {
+	Fact      *UniMemFact
+	ThenIndex int // index of the then fact which unifies with the goal
+	VarSubst  map[string]parser.Fc
+	TypeSubst map[parser.TypeVarStr]parser.TypeVarStr
 }
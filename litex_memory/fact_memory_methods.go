@@ -3,6 +3,7 @@ package litexmemory
 import (
 	"fmt"
 	parser "golitex/litex_parser"
+	"strings"
 )
 
 func CompSpecFactParams(knownFact parser.SpecFactStmt, givenFact parser.SpecFactStmt) int {
@@ -35,7 +36,15 @@ func specFactWithTheSameTypeCompare(knownFact *parser.SpecFactStmt, givenFact *p
 }
 
 func specRelationFactCompare(knownFact *parser.RelationFactStmt, givenFact *parser.RelationFactStmt) (int, error) {
-	panic("TODO not implemented")
+	if isTrueComp := compareIsTrue(knownFact.IsTrue, givenFact.IsTrue); isTrueComp != 0 {
+		return isTrueComp, nil
+	}
+
+	if optComp, err := compareFc(knownFact.Opt, givenFact.Opt); optComp != 0 || err != nil {
+		return optComp, err
+	}
+
+	return compareFcSlice(knownFact.Vars, givenFact.Vars)
 }
 
 const (
@@ -44,13 +53,17 @@ const (
 )
 
 func specFuncIsTrueCompare(knownFact *parser.FuncFactStmt, givenFact *parser.FuncFactStmt) int {
+	return compareIsTrue(knownFact.IsTrue, givenFact.IsTrue)
+}
+
+func compareIsTrue(knownIsTrue bool, givenIsTrue bool) int {
 	knownFactIsTrueEnum := isTrueEnum
-	if !knownFact.IsTrue {
+	if !knownIsTrue {
 		knownFactIsTrueEnum = isNotTrueEnum
 	}
 
 	givenFactIsTrueEnum := isTrueEnum
-	if !givenFact.IsTrue {
+	if !givenIsTrue {
 		givenFactIsTrueEnum = isNotTrueEnum
 	}
 
@@ -69,6 +82,8 @@ const (
 	fcStrEnum        = 0
 	fcFnRetValueEnum = 1
 	FcMemChainEnum   = 2
+	fcLambdaFnEnum   = 3
+	fcLambdaPropEnum = 4
 )
 
 func getFcEnum(fc parser.Fc) (int, error) {
@@ -87,6 +102,16 @@ func getFcEnum(fc parser.Fc) (int, error) {
 		return FcMemChainEnum, nil
 	}
 
+	_, ok = fc.(*parser.FcLambdaFn)
+	if ok {
+		return fcLambdaFnEnum, nil
+	}
+
+	_, ok = fc.(*parser.FcLambdaProp)
+	if ok {
+		return fcLambdaPropEnum, nil
+	}
+
 	return 0, fmt.Errorf("unknown Fc type: %T", fc)
 }
 
@@ -99,7 +124,63 @@ func compareFc(knownFc parser.Fc, givenFc parser.Fc) (int, error) {
 }
 
 func compareFcOfTheSameType(knownFc parser.Fc, givenFc parser.Fc) (int, error) {
-	panic("")
+	switch known := knownFc.(type) {
+	case parser.FcStr:
+		return strings.Compare(known.Value, givenFc.(parser.FcStr).Value), nil
+	case *parser.FcFnRetValue:
+		return compareFcFnRetValue(known, givenFc.(*parser.FcFnRetValue))
+	case *parser.FcMemChain:
+		return compareFcSlice(known.Members, givenFc.(*parser.FcMemChain).Members)
+	case *parser.FcLambdaFn, *parser.FcLambdaProp:
+		// lambdas which differ only in the names of their parameters are equal
+		return strings.Compare(parser.CanonicalFc(known).String(), parser.CanonicalFc(givenFc).String()), nil
+	}
+
+	return 0, fmt.Errorf("unknown Fc type: %T", knownFc)
+}
+
+func compareFcFnRetValue(knownFc *parser.FcFnRetValue, givenFc *parser.FcFnRetValue) (int, error) {
+	if nameComp := strings.Compare(knownFc.FnName.Value, givenFc.FnName.Value); nameComp != 0 {
+		return nameComp, nil
+	}
+
+	if lenComp := len(knownFc.TypeParamsVarParamsPairs) - len(givenFc.TypeParamsVarParamsPairs); lenComp != 0 {
+		return lenComp, nil
+	}
+
+	for i, knownPair := range knownFc.TypeParamsVarParamsPairs {
+		givenPair := givenFc.TypeParamsVarParamsPairs[i]
+
+		if lenComp := len(knownPair.TypeParams) - len(givenPair.TypeParams); lenComp != 0 {
+			return lenComp, nil
+		}
+
+		for j, knownTypeParam := range knownPair.TypeParams {
+			if comp := strings.Compare(string(knownTypeParam), string(givenPair.TypeParams[j])); comp != 0 {
+				return comp, nil
+			}
+		}
+
+		if comp, err := compareFcSlice(knownPair.VarParams, givenPair.VarParams); comp != 0 || err != nil {
+			return comp, err
+		}
+	}
+
+	return 0, nil
+}
+
+func compareFcSlice(knownFcs []parser.Fc, givenFcs []parser.Fc) (int, error) {
+	if lenComp := len(knownFcs) - len(givenFcs); lenComp != 0 {
+		return lenComp, nil
+	}
+
+	for i, knownFc := range knownFcs {
+		if comp, err := compareFc(knownFc, givenFcs[i]); comp != 0 || err != nil {
+			return comp, err
+		}
+	}
+
+	return 0, nil
 }
 
 func compareFcType(knownFc parser.Fc, givenFc parser.Fc) (int, error) {
@@ -149,13 +230,435 @@ func getSpecFactEnum(fact *parser.SpecFactStmt) (int, error) {
 
 func NewSpecFactMemory() *SpecFactMemory {
 
-	return &SpecFactMemory{KnownFacts: RedBlackTree{}}
+	return &SpecFactMemory{knownFacts: *NewRedBlackTree(specFactTreeCompare)}
+}
+
+func specFactTreeCompare(a, b interface{}) (int, error) {
+	knownFact, err := specFactOfTreeKey(a)
+	if err != nil {
+		return 0, err
+	}
+
+	givenFact, err := specFactOfTreeKey(b)
+	if err != nil {
+		return 0, err
+	}
+
+	return SpecFactCompare(&knownFact, &givenFact)
+}
+
+// the tree stores *SpecMemFact, and it is searched with either *SpecMemFact or parser.SpecFactStmt
+func specFactOfTreeKey(key interface{}) (parser.SpecFactStmt, error) {
+	switch key := key.(type) {
+	case *SpecMemFact:
+		return key.Fact, nil
+	case parser.SpecFactStmt:
+		return key, nil
+	}
+	return nil, fmt.Errorf("invalid key type %T", key)
 }
 
 func NewUniFactMemory() *UniFactMemory {
-	return &UniFactMemory{map[PropName]UniFactMemEntry{}}
+	return &UniFactMemory{entries: map[PropName]UniFactMemEntry{}}
 }
 
 func NewCondFactMemory() *CondFactMemory {
-	return &CondFactMemory{KVs: map[PropName]CondFactMemEntry{}}
+	return &CondFactMemory{entries: map[PropName]CondFactMemEntry{}}
+}
+
+func newUniMemFact(fact *parser.BlockForallStmt, provenance FactProvenance) *UniMemFact {
+	return &UniMemFact{&fact.TypeParams, &fact.VarParams, &fact.Cond, &fact.Then, provenance}
+}
+
+func (fact *UniMemFact) stmt() *parser.BlockForallStmt {
+	return &parser.BlockForallStmt{TypeParams: *fact.typeParams, VarParams: *fact.varParams, Cond: *fact.cond, Then: *fact.then}
+}
+
+// NewFact stores a universal fact under the prop name of each of its then facts and indexes the shapes of the then facts.
+func (mem *UniFactMemory) NewFact(fact *parser.BlockForallStmt, provenance FactProvenance) error {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	toStore := newUniMemFact(fact, provenance)
+
+	for thenIndex, thenFact := range fact.Then {
+		propName, err := getSpecFactPropName(thenFact)
+		if err != nil {
+			return err
+		}
+
+		flattener := newDiscTreeFlattener(toStore.typeParams, toStore.varParams)
+		if _, ok := flattener.boundVars[string(propName)]; ok {
+			propName = anyPropName
+		}
+
+		if err := flattener.flattenSpecFact(thenFact); err != nil {
+			return err
+		}
+
+		entry, ok := mem.entries[propName]
+		if !ok {
+			entry = UniFactMemEntry{[]UniMemFact{}, newDiscTreeNode()}
+		}
+
+		// the same fact is stored once per entry even if several of its then facts share the prop name
+		factIndex := len(entry.Facts) - 1
+		if factIndex < 0 || entry.Facts[factIndex].then != toStore.then {
+			entry.Facts = append(entry.Facts, *toStore)
+			factIndex++
+		}
+
+		entry.tree.insert(flattener.keys, discTreeLeaf{factIndex, thenIndex})
+		mem.entries[propName] = entry
+	}
+
+	return nil
+}
+
+// Traverse visits stored universal facts in the order of the prop names of their first then facts. Each fact is visited once.
+func (mem *UniFactMemory) Traverse(visit func(fact *parser.BlockForallStmt, provenance FactProvenance) error) error {
+	for _, fact := range mem.uniqueFacts() {
+		if err := visit(fact.stmt(), fact.provenance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mem *UniFactMemory) uniqueFacts() []UniMemFact {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+
+	ret := []UniMemFact{}
+	visited := map[*[]parser.SpecFactStmt]struct{}{}
+	for _, propName := range sortedKeys(mem.entries) {
+		for _, fact := range mem.entries[propName].Facts {
+			if _, ok := visited[fact.then]; ok {
+				continue
+			}
+			visited[fact.then] = struct{}{}
+			ret = append(ret, fact)
+		}
+	}
+	return ret
+}
+
+// GetCandidates returns the stored universal facts which have a then fact that unifies with the goal, together with the substitution.
+func (mem *UniFactMemory) GetCandidates(goal parser.SpecFactStmt) ([]UniFactCandidate, error) {
+	propName, err := getSpecFactPropName(goal)
+	if err != nil {
+		return nil, err
+	}
+
+	flattener := newDiscTreeFlattener(nil, nil)
+	if err := flattener.flattenSpecFact(goal); err != nil {
+		return nil, err
+	}
+
+	names := []PropName{propName}
+	if propName != anyPropName {
+		names = append(names, anyPropName)
+	}
+
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+
+	ret := []UniFactCandidate{}
+	for _, name := range names {
+		entry, ok := mem.entries[name]
+		if !ok {
+			continue
+		}
+
+		err := entry.tree.retrieve(flattener.keys, 0, func(leaf discTreeLeaf) error {
+			fact := &entry.Facts[leaf.factIndex]
+			matcher := newUniFactMatcher(fact)
+			ok, err := matcher.matchSpecFact((*fact.then)[leaf.thenIndex], goal)
+			if err != nil || !ok {
+				return err
+			}
+			ret = append(ret, UniFactCandidate{fact, leaf.thenIndex, matcher.varSubst, matcher.typeSubst})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return ret, nil
+}
+
+func (mem *SpecFactMemory) NewFact(fact parser.SpecFactStmt, provenance FactProvenance) error {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	return mem.knownFacts.Insert(&SpecMemFact{fact, provenance})
+}
+
+// Traverse visits known spec facts in ascending order.
+func (mem *SpecFactMemory) Traverse(visit func(fact parser.SpecFactStmt, provenance FactProvenance) error) error {
+	facts, err := mem.sortedFacts()
+	if err != nil {
+		return err
+	}
+
+	for _, fact := range facts {
+		if err := visit(fact.Fact, fact.Provenance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mem *SpecFactMemory) sortedFacts() ([]*SpecMemFact, error) {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+
+	ret := []*SpecMemFact{}
+	err := mem.knownFacts.InOrderTraversal(mem.knownFacts.root, func(key interface{}) error {
+		ret = append(ret, key.(*SpecMemFact))
+		return nil
+	})
+	return ret, err
+}
+
+// NewFact stores the conditional fact once for each of its then facts, under the prop name of that then fact.
+func (mem *CondFactMemory) NewFact(fact *parser.IfFactStmt, provenance FactProvenance) error {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	for _, thenFact := range fact.ThenFacts {
+		propName, err := getSpecFactPropName(thenFact)
+		if err != nil {
+			return err
+		}
+
+		entry := mem.entries[propName]
+		entry.Facts = append(entry.Facts, CondFactMemFact{&fact.CondFacts, thenFact, provenance})
+		mem.entries[propName] = entry
+	}
+
+	return nil
+}
+
+// Traverse visits stored conditional facts in the order of their prop names. Every visited fact has exactly one then fact.
+func (mem *CondFactMemory) Traverse(visit func(fact *parser.IfFactStmt, provenance FactProvenance) error) error {
+	_, entries := sortedEntries(&mem.mu, mem.entries)
+	for _, entry := range entries {
+		for _, fact := range entry.Facts {
+			if err := visit(fact.stmt(), fact.provenance); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (fact *CondFactMemFact) stmt() *parser.IfFactStmt {
+	return &parser.IfFactStmt{CondFacts: *fact.cond, ThenFacts: []parser.SpecFactStmt{fact.then}}
+}
+
+func (mem *SpecFactMemory) Len() int {
+	ret := 0
+	mem.Traverse(func(fact parser.SpecFactStmt, _ FactProvenance) error {
+		ret++
+		return nil
+	})
+	return ret
+}
+
+func (mem *CondFactMemory) Len() int {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+
+	ret := 0
+	for _, entry := range mem.entries {
+		ret += len(entry.Facts)
+	}
+	return ret
+}
+
+func (mem *UniFactMemory) Len() int {
+	ret := 0
+	mem.Traverse(func(fact *parser.BlockForallStmt, _ FactProvenance) error {
+		ret++
+		return nil
+	})
+	return ret
+}
+
+func (mem *SpecFactMemory) GetFactsOfProp(name PropName) ([]parser.SpecFactStmt, error) {
+	ret := []parser.SpecFactStmt{}
+	err := mem.Traverse(func(fact parser.SpecFactStmt, _ FactProvenance) error {
+		propName, err := getSpecFactPropName(fact)
+		if err != nil {
+			return err
+		}
+		if propName == name {
+			ret = append(ret, fact)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+func (mem *CondFactMemory) GetFactsOfProp(name PropName) []*parser.IfFactStmt {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+
+	ret := []*parser.IfFactStmt{}
+	for _, fact := range mem.entries[name].Facts {
+		ret = append(ret, fact.stmt())
+	}
+	return ret
+}
+
+func (mem *UniFactMemory) GetFactsOfProp(name PropName) []*parser.BlockForallStmt {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+
+	ret := []*parser.BlockForallStmt{}
+	for _, fact := range mem.entries[name].Facts {
+		ret = append(ret, fact.stmt())
+	}
+	return ret
+}
+
+// GetFactsAbout returns known spec facts in which fc appears.
+func (mem *SpecFactMemory) GetFactsAbout(fc parser.Fc) ([]parser.SpecFactStmt, error) {
+	ret := []parser.SpecFactStmt{}
+	err := mem.Traverse(func(fact parser.SpecFactStmt, _ FactProvenance) error {
+		ok, err := factMentionsFc(fact, fc)
+		if err != nil {
+			return err
+		}
+		if ok {
+			ret = append(ret, fact)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// GetFactsAbout returns conditional facts in which fc appears.
+func (mem *CondFactMemory) GetFactsAbout(fc parser.Fc) ([]*parser.IfFactStmt, error) {
+	ret := []*parser.IfFactStmt{}
+	err := mem.Traverse(func(fact *parser.IfFactStmt, _ FactProvenance) error {
+		ok, err := factMentionsFc(fact, fc)
+		if err != nil {
+			return err
+		}
+		if ok {
+			ret = append(ret, fact)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// GetFactsAbout returns universal facts in which fc appears. Parameters of the forall which share the name of fc are not fc.
+func (mem *UniFactMemory) GetFactsAbout(fc parser.Fc) ([]*parser.BlockForallStmt, error) {
+	ret := []*parser.BlockForallStmt{}
+	err := mem.Traverse(func(fact *parser.BlockForallStmt, _ FactProvenance) error {
+		ok, err := factMentionsFc(fact, fc)
+		if err != nil {
+			return err
+		}
+		if ok {
+			ret = append(ret, fact)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+func factMentionsFc(fact parser.FactStmt, fc parser.Fc) (bool, error) {
+	switch fact := fact.(type) {
+	case *parser.FuncFactStmt:
+		return fcMentionsFc(fact.Fc, fc)
+	case *parser.RelationFactStmt:
+		return fcSliceMentionsFc(fact.Vars, fc)
+	case *parser.IfFactStmt:
+		for _, cond := range fact.CondFacts {
+			if ok, err := factMentionsFc(cond, fc); ok || err != nil {
+				return ok, err
+			}
+		}
+		for _, then := range fact.ThenFacts {
+			if ok, err := factMentionsFc(then, fc); ok || err != nil {
+				return ok, err
+			}
+		}
+		return false, nil
+	case *parser.BlockForallStmt:
+		if paramsShadowFc(fact.VarParams, fc) {
+			return false, nil
+		}
+		for _, cond := range fact.Cond {
+			if ok, err := factMentionsFc(cond, fc); ok || err != nil {
+				return ok, err
+			}
+		}
+		for _, then := range fact.Then {
+			if ok, err := factMentionsFc(then, fc); ok || err != nil {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+
+	return false, fmt.Errorf("unknown fact type: %T", fact)
+}
+
+func fcMentionsFc(haystack parser.Fc, needle parser.Fc) (bool, error) {
+	if comp, err := compareFc(haystack, needle); comp == 0 || err != nil {
+		return err == nil, err
+	}
+
+	switch haystack := haystack.(type) {
+	case *parser.FcFnRetValue:
+		if ok, err := fcMentionsFc(haystack.FnName, needle); ok || err != nil {
+			return ok, err
+		}
+		for _, pair := range haystack.TypeParamsVarParamsPairs {
+			if ok, err := fcSliceMentionsFc(pair.VarParams, needle); ok || err != nil {
+				return ok, err
+			}
+		}
+	case *parser.FcMemChain:
+		return fcSliceMentionsFc(haystack.Members, needle)
+	case *parser.FcLambdaFn:
+		if paramsShadowFc(haystack.Params, needle) {
+			return false, nil
+		}
+		return fcMentionsFc(haystack.Body, needle)
+	case *parser.FcLambdaProp:
+		if paramsShadowFc(haystack.Params, needle) {
+			return false, nil
+		}
+		return factMentionsFc(haystack.Body, needle)
+	}
+
+	return false, nil
+}
+
+func paramsShadowFc(params []parser.StrTypePair, fc parser.Fc) bool {
+	if fcStr, ok := fc.(parser.FcStr); ok {
+		for _, param := range params {
+			if param.Var == fcStr.Value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func fcSliceMentionsFc(haystacks []parser.Fc, needle parser.Fc) (bool, error) {
+	for _, haystack := range haystacks {
+		if ok, err := fcMentionsFc(haystack, needle); ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
 }
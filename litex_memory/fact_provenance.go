@@ -0,0 +1,90 @@
+package litexmemory
+
+import (
+	"fmt"
+	parser "golitex/litex_parser"
+)
+
+// FactOrigin tells which kind of statement made a fact known.
+type FactOrigin uint8
+
+const (
+	FactOriginKnow FactOrigin = iota
+	FactOriginAxiom
+	FactOriginThm
+	FactOriginClaim
+	FactOriginDefUnfolding // facts which follow from the definition of a prop, fn or type
+	FactOriginHave
+)
+
+var factOriginNames = map[FactOrigin]string{
+	FactOriginKnow:         "know",
+	FactOriginAxiom:        "axiom",
+	FactOriginThm:          "thm",
+	FactOriginClaim:        "claim",
+	FactOriginDefUnfolding: "def",
+	FactOriginHave:         "have",
+}
+
+func (origin FactOrigin) String() string {
+	if name, ok := factOriginNames[origin]; ok {
+		return name
+	}
+	return fmt.Sprintf("FactOrigin(%d)", uint8(origin))
+}
+
+func (origin FactOrigin) MarshalText() ([]byte, error) {
+	if _, ok := factOriginNames[origin]; !ok {
+		return nil, fmt.Errorf("unknown fact origin %d", uint8(origin))
+	}
+	return []byte(origin.String()), nil
+}
+
+func (origin *FactOrigin) UnmarshalText(text []byte) error {
+	for cur, name := range factOriginNames {
+		if name == string(text) {
+			*origin = cur
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown fact origin %s", text)
+}
+
+// FactProvenance records where a stored fact came from.
+type FactProvenance struct {
+	Origin       FactOrigin `json:"origin"`
+	File         string     `json:"file"`           // empty if the source code is not read from a file
+	Line         int        `json:"line"`           // line of the top-level statement, starting from 1. 0 if unknown
+	TopStmtIndex int        `json:"top_stmt_index"` // index of the top-level statement in its file
+}
+
+// e.g. know at lib.litex:12 (statement 3)
+func (p FactProvenance) String() string {
+	file := p.File
+	if file == "" {
+		file = "<source>"
+	}
+	return fmt.Sprintf("%s at %s:%d (statement %d)", p.Origin, file, p.Line, p.TopStmtIndex)
+}
+
+// SpecMemFact is the key of the tree of SpecFactMemory. Keys are ordered by Fact only.
+type SpecMemFact struct {
+	Fact       parser.SpecFactStmt
+	Provenance FactProvenance
+}
+
+func (fact *UniMemFact) Provenance() FactProvenance {
+	return fact.provenance
+}
+
+// GetProvenance returns the provenance of a known spec fact. If the fact is known more than once, the first one inserted is returned.
+func (mem *SpecFactMemory) GetProvenance(fact parser.SpecFactStmt) (*FactProvenance, bool, error) {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+
+	key, ok, err := mem.knownFacts.Search(fact)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return &key.(*SpecMemFact).Provenance, true, nil
+}
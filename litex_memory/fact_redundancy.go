@@ -0,0 +1,314 @@
+package litexmemory
+
+import (
+	"fmt"
+	parser "golitex/litex_parser"
+)
+
+// Methods below check a new fact against the facts of a memory before the new fact is stored.
+// A spec fact counts as known when isKnown says so. Conds which are not spec facts are never known,
+// so universal facts with such conds imply nothing here.
+
+type FactRedundancyKind uint8
+
+const (
+	FactDuplicate        FactRedundancyKind = iota // the same fact is already known
+	FactImpliedByUniFact                           // the new fact is an instance of a known universal fact
+	FactSubsumesOlder                              // the new fact makes an older fact redundant
+)
+
+// FactRedundancy describes how a new fact relates to an older fact.
+type FactRedundancy struct {
+	Kind       FactRedundancyKind
+	Fact       parser.FactStmt // the older fact
+	Provenance FactProvenance  // provenance of the older fact
+}
+
+func (r *FactRedundancy) String() string {
+	switch r.Kind {
+	case FactDuplicate:
+		return fmt.Sprintf("already known from %s", r.Provenance)
+	case FactImpliedByUniFact:
+		return fmt.Sprintf("implied by the universal fact from %s:\n%s", r.Provenance, r.Fact)
+	case FactSubsumesOlder:
+		return fmt.Sprintf("makes the fact from %s redundant:\n%s", r.Provenance, r.Fact)
+	}
+	return fmt.Sprintf("FactRedundancyKind(%d)", uint8(r.Kind))
+}
+
+// Facts other than spec facts are compared by their source, which is canonical.
+func factsEqual(left []parser.FactStmt, right []parser.FactStmt) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for i := range left {
+		if left[i].String() != right[i].String() {
+			return false
+		}
+	}
+	return true
+}
+
+func (mem *SpecFactMemory) GetDuplicate(fact parser.SpecFactStmt) (*FactRedundancy, error) {
+	provenance, ok, err := mem.GetProvenance(fact)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &FactRedundancy{FactDuplicate, fact, *provenance}, nil
+}
+
+// GetDuplicate returns a known conditional fact with the same conds and a then fact equal to each then fact of fact.
+func (mem *CondFactMemory) GetDuplicate(fact *parser.IfFactStmt) (*FactRedundancy, error) {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+
+	var ret *FactRedundancy
+	for _, thenFact := range fact.ThenFacts {
+		propName, err := getSpecFactPropName(thenFact)
+		if err != nil {
+			return nil, err
+		}
+
+		var found *CondFactMemFact
+		for i, known := range mem.entries[propName].Facts {
+			comp, err := SpecFactCompare(&known.then, &thenFact)
+			if err != nil {
+				return nil, err
+			}
+			if comp == 0 && factsEqual(*known.cond, fact.CondFacts) {
+				found = &mem.entries[propName].Facts[i]
+				break
+			}
+		}
+
+		if found == nil {
+			return nil, nil
+		}
+		if ret == nil {
+			ret = &FactRedundancy{FactDuplicate, fact, found.provenance}
+		}
+	}
+	return ret, nil
+}
+
+func (mem *UniFactMemory) GetDuplicate(fact *parser.BlockForallStmt) (*FactRedundancy, error) {
+	if len(fact.Then) == 0 {
+		return nil, nil
+	}
+
+	propName, err := getSpecFactPropName(fact.Then[0])
+	if err != nil {
+		return nil, err
+	}
+	if newDiscTreeFlattener(nil, &fact.VarParams).isBoundVar(string(propName)) {
+		propName = anyPropName
+	}
+
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+
+	source := fact.String()
+	for _, known := range mem.entries[propName].Facts {
+		if known.stmt().String() == source {
+			return &FactRedundancy{FactDuplicate, fact, known.provenance}, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetImplyingFact returns a universal fact which has goal as an instance of a then fact and whose instantiated conds are all known.
+func (mem *UniFactMemory) GetImplyingFact(goal parser.SpecFactStmt, isKnown func(parser.SpecFactStmt) (bool, error)) (*FactRedundancy, error) {
+	candidates, err := mem.GetCandidates(goal)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, candidate := range candidates {
+		ok, err := instantiatedCondsAreKnown(*candidate.Fact.cond, candidate.VarSubst, candidate.TypeSubst, isKnown)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return &FactRedundancy{FactImpliedByUniFact, candidate.Fact.stmt(), candidate.Fact.provenance}, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetInstancesOf returns the known spec facts which are instances of a then fact of fact, with known instantiated conds.
+func (mem *SpecFactMemory) GetInstancesOf(fact *parser.BlockForallStmt, isKnown func(parser.SpecFactStmt) (bool, error)) ([]FactRedundancy, error) {
+	uniFact := newUniMemFact(fact, FactProvenance{})
+	ret := []FactRedundancy{}
+
+	err := mem.Traverse(func(known parser.SpecFactStmt, provenance FactProvenance) error {
+		for _, thenFact := range fact.Then {
+			matcher := newUniFactMatcher(uniFact)
+			ok, err := matcher.matchSpecFact(thenFact, known)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+
+			ok, err = instantiatedCondsAreKnown(fact.Cond, matcher.varSubst, matcher.typeSubst, isKnown)
+			if err != nil {
+				return err
+			}
+			if ok {
+				ret = append(ret, FactRedundancy{FactSubsumesOlder, known, provenance})
+				return nil
+			}
+		}
+		return nil
+	})
+
+	return ret, err
+}
+
+// GetFactsConcluding returns the known conditional facts whose then fact is fact. Knowing fact makes them redundant.
+func (mem *CondFactMemory) GetFactsConcluding(fact parser.SpecFactStmt) ([]FactRedundancy, error) {
+	propName, err := getSpecFactPropName(fact)
+	if err != nil {
+		return nil, err
+	}
+
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+
+	ret := []FactRedundancy{}
+	for _, known := range mem.entries[propName].Facts {
+		comp, err := SpecFactCompare(&known.then, &fact)
+		if err != nil {
+			return nil, err
+		}
+		if comp == 0 {
+			ret = append(ret, FactRedundancy{FactSubsumesOlder, known.stmt(), known.provenance})
+		}
+	}
+	return ret, nil
+}
+
+func instantiatedCondsAreKnown(conds []parser.FactStmt, varSubst map[string]parser.Fc, typeSubst map[parser.TypeVarStr]parser.TypeVarStr, isKnown func(parser.SpecFactStmt) (bool, error)) (bool, error) {
+	for _, cond := range conds {
+		specCond, ok := cond.(parser.SpecFactStmt)
+		if !ok {
+			return false, nil
+		}
+
+		instance, err := instantiateSpecFact(specCond, varSubst, typeSubst)
+		if err != nil {
+			return false, err
+		}
+
+		if ok, err := isKnown(instance); !ok || err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func instantiateSpecFact(fact parser.SpecFactStmt, varSubst map[string]parser.Fc, typeSubst map[parser.TypeVarStr]parser.TypeVarStr) (parser.SpecFactStmt, error) {
+	switch fact := fact.(type) {
+	case *parser.FuncFactStmt:
+		// $P(a) with P bound to a prop lambda is the body of the lambda applied to a
+		if call, ok := fact.Fc.(*parser.FcFnRetValue); ok {
+			if lambda, ok := varSubst[call.FnName.Value].(*parser.FcLambdaProp); ok {
+				args, err := instantiateCallArgs(call, varSubst, typeSubst)
+				if err != nil {
+					return nil, err
+				}
+				return parser.SubstituteSpecFact(&parser.FuncFactStmt{IsTrue: fact.IsTrue, Fc: args}, map[string]parser.Fc{call.FnName.Value: lambda})
+			}
+		}
+
+		fc, err := instantiateFc(fact.Fc, varSubst, typeSubst)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.FuncFactStmt{IsTrue: fact.IsTrue, Fc: fc}, nil
+	case *parser.RelationFactStmt:
+		vars, err := instantiateFcSlice(fact.Vars, varSubst, typeSubst)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.RelationFactStmt{IsTrue: fact.IsTrue, Vars: vars, Opt: fact.Opt}, nil
+	}
+
+	return nil, fmt.Errorf("unknown SpecFactStmt type: %T", fact)
+}
+
+func instantiateFc(fc parser.Fc, varSubst map[string]parser.Fc, typeSubst map[parser.TypeVarStr]parser.TypeVarStr) (parser.Fc, error) {
+	switch fc := fc.(type) {
+	case parser.FcStr:
+		if value, ok := varSubst[fc.Value]; ok {
+			return value, nil
+		}
+		return fc, nil
+
+	case *parser.FcFnRetValue:
+		ret, err := instantiateCallArgs(fc, varSubst, typeSubst)
+		if err != nil {
+			return nil, err
+		}
+
+		value, ok := varSubst[fc.FnName.Value]
+		if !ok {
+			return ret, nil
+		}
+		switch value := value.(type) {
+		case parser.FcStr:
+			ret.FnName = value
+			return ret, nil
+		case *parser.FcLambdaFn:
+			// f(a) with f bound to a fn lambda is the body of the lambda applied to a
+			return parser.SubstituteFc(ret, map[string]parser.Fc{fc.FnName.Value: value})
+		}
+		return nil, fmt.Errorf("%s is bound to %s, which is not a fn name", fc.FnName, value)
+
+	case *parser.FcMemChain:
+		members, err := instantiateFcSlice(fc.Members, varSubst, typeSubst)
+		if err != nil {
+			return nil, err
+		}
+		return &parser.FcMemChain{Members: members, Span: fc.Span}, nil
+
+	case *parser.FcLambdaFn, *parser.FcLambdaProp:
+		return parser.SubstituteFc(fc, varSubst)
+	}
+
+	return nil, fmt.Errorf("unknown Fc type: %T", fc)
+}
+
+// instantiateCallArgs instantiates the type and var params of fc, keeping its fn name.
+func instantiateCallArgs(fc *parser.FcFnRetValue, varSubst map[string]parser.Fc, typeSubst map[parser.TypeVarStr]parser.TypeVarStr) (*parser.FcFnRetValue, error) {
+	ret := &parser.FcFnRetValue{FnName: fc.FnName, TypeParamsVarParamsPairs: make([]parser.TypeParamsAndParamsPair, len(fc.TypeParamsVarParamsPairs))}
+	for i, pair := range fc.TypeParamsVarParamsPairs {
+		typeParams := make([]parser.TypeVarStr, len(pair.TypeParams))
+		for j, tp := range pair.TypeParams {
+			if value, ok := typeSubst[tp]; ok {
+				tp = value
+			}
+			typeParams[j] = tp
+		}
+
+		varParams, err := instantiateFcSlice(pair.VarParams, varSubst, typeSubst)
+		if err != nil {
+			return nil, err
+		}
+		ret.TypeParamsVarParamsPairs[i] = parser.TypeParamsAndParamsPair{TypeParams: typeParams, VarParams: varParams}
+	}
+	return ret, nil
+}
+
+func instantiateFcSlice(fcs []parser.Fc, varSubst map[string]parser.Fc, typeSubst map[parser.TypeVarStr]parser.TypeVarStr) ([]parser.Fc, error) {
+	ret := make([]parser.Fc, len(fcs))
+	for i, fc := range fcs {
+		cur, err := instantiateFc(fc, varSubst, typeSubst)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = cur
+	}
+	return ret, nil
+}
@@ -0,0 +1,154 @@
+package litexmemory
+
+import (
+	"fmt"
+	parser "golitex/litex_parser"
+	"strconv"
+	"strings"
+)
+
+// FcID identifies a structurally distinct Fc in an FcInternTable. Two Fc are structurally equal iff they have the same FcID.
+type FcID uint32
+
+// FcInternTable hash-conses Fc. Every structurally distinct term is stored once, and the stored term is built from
+// the stored terms of its subterms, so shared subterms take memory only once.
+type FcInternTable struct {
+	ids   map[string]FcID
+	terms []parser.Fc
+}
+
+func NewFcInternTable() *FcInternTable {
+	return &FcInternTable{ids: map[string]FcID{}, terms: []parser.Fc{}}
+}
+
+// Intern returns the ID of fc, storing fc and its subterms if they are not in the table yet.
+func (table *FcInternTable) Intern(fc parser.Fc) (FcID, error) {
+	key, canonical, err := table.internKey(fc, true)
+	if err != nil {
+		return 0, err
+	}
+
+	if id, ok := table.ids[key]; ok {
+		return id, nil
+	}
+
+	id := FcID(len(table.terms))
+	table.ids[key] = id
+	table.terms = append(table.terms, canonical)
+	return id, nil
+}
+
+// Get returns the ID of fc without storing anything.
+func (table *FcInternTable) Get(fc parser.Fc) (FcID, bool) {
+	key, _, err := table.internKey(fc, false)
+	if err != nil {
+		return 0, false
+	}
+
+	id, ok := table.ids[key]
+	return id, ok
+}
+
+// Term returns the stored term of the given ID.
+func (table *FcInternTable) Term(id FcID) (parser.Fc, error) {
+	if int(id) >= len(table.terms) {
+		return nil, fmt.Errorf("unknown Fc id %d", id)
+	}
+	return table.terms[id], nil
+}
+
+func (table *FcInternTable) Len() int {
+	return len(table.terms)
+}
+
+// Equal reports whether two Fc are structurally equal. It does not store anything: interned terms
+// are compared by their IDs, and terms which are not interned are compared structurally.
+func (table *FcInternTable) Equal(left parser.Fc, right parser.Fc) (bool, error) {
+	leftID, leftOk := table.Get(left)
+	rightID, rightOk := table.Get(right)
+	if leftOk && rightOk {
+		return leftID == rightID, nil
+	}
+	// a term equal to an interned term is interned as well
+	if leftOk || rightOk {
+		return false, nil
+	}
+
+	comp, err := compareFc(left, right)
+	return comp == 0, err
+}
+
+// internKey returns the key of fc, which only contains the IDs of its direct subterms, so it is built in time linear to
+// the size of fc rather than of the whole tree. When intern is false, a subterm that is not in the table yields an
+// error because fc can not be in the table either.
+func (table *FcInternTable) internKey(fc parser.Fc, intern bool) (string, parser.Fc, error) {
+	switch fc := fc.(type) {
+	case parser.FcStr:
+		return "s" + strconv.Quote(fc.Value), parser.FcStr{Value: fc.Value}, nil
+
+	case *parser.FcFnRetValue:
+		var builder strings.Builder
+		builder.WriteString("f" + strconv.Quote(fc.FnName.Value))
+
+		canonical := &parser.FcFnRetValue{FnName: parser.FcStr{Value: fc.FnName.Value}, TypeParamsVarParamsPairs: make([]parser.TypeParamsAndParamsPair, len(fc.TypeParamsVarParamsPairs))}
+		for i, pair := range fc.TypeParamsVarParamsPairs {
+			builder.WriteString("[")
+			for _, tp := range pair.TypeParams {
+				builder.WriteString(strconv.Quote(string(tp)))
+			}
+			builder.WriteString("](")
+
+			varParams, err := table.internSubterms(&builder, pair.VarParams, intern)
+			if err != nil {
+				return "", nil, err
+			}
+			builder.WriteString(")")
+
+			canonical.TypeParamsVarParamsPairs[i] = parser.TypeParamsAndParamsPair{TypeParams: pair.TypeParams, VarParams: varParams}
+		}
+
+		return builder.String(), canonical, nil
+
+	case *parser.FcMemChain:
+		var builder strings.Builder
+		builder.WriteString("c(")
+		members, err := table.internSubterms(&builder, fc.Members, intern)
+		if err != nil {
+			return "", nil, err
+		}
+		builder.WriteString(")")
+
+		return builder.String(), &parser.FcMemChain{Members: members}, nil
+
+	case *parser.FcLambdaFn, *parser.FcLambdaProp:
+		// lambdas are interned whole, by the source of their canonical form
+		canonical := parser.CanonicalFc(fc)
+		return "l" + strconv.Quote(canonical.String()), canonical, nil
+	}
+
+	return "", nil, fmt.Errorf("unknown Fc type: %T", fc)
+}
+
+func (table *FcInternTable) internSubterms(builder *strings.Builder, subterms []parser.Fc, intern bool) ([]parser.Fc, error) {
+	canonical := make([]parser.Fc, len(subterms))
+	for i, subterm := range subterms {
+		var id FcID
+		if intern {
+			var err error
+			id, err = table.Intern(subterm)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			var ok bool
+			id, ok = table.Get(subterm)
+			if !ok {
+				return nil, fmt.Errorf("%v is not interned", subterm)
+			}
+		}
+
+		builder.WriteString(strconv.FormatUint(uint64(id), 10) + ",")
+		canonical[i] = table.terms[id]
+	}
+	return canonical, nil
+}
@@ -1,9 +1,20 @@
 package litexmemory
 
-import parser "golitex/litex_parser"
+import (
+	"fmt"
+	parser "golitex/litex_parser"
+	"sort"
+	"sync"
+)
+
+// Memories may be read by many goroutines while one goroutine writes. Traverse visits a copy of the entries taken
+// under the read lock, so visit may call any method of the memory, including methods which write.
 
 func (mem *VarMemory) Get(s string) (*VarMemoryEntry, bool) {
-	ret, ok := mem.KVs[s]
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+
+	ret, ok := mem.entries[s]
 	if !ok {
 		return nil, false
 	}
@@ -15,22 +26,302 @@ func (mem *VarMemory) Set(pair *parser.FcVarDeclPair) (*VarMemoryEntry, error) {
 		pair.Tp,
 		[]parser.FcVarType{pair.Tp},
 	}
-	mem.KVs[pair.Var] = toStore
+	return mem.SetEntry(pair.Var, toStore), nil
+}
 
-	return &toStore, nil
+// SetEntry stores an entry as it is, e.g. one which was saved before.
+func (mem *VarMemory) SetEntry(name string, entry VarMemoryEntry) *VarMemoryEntry {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	mem.entries[name] = entry
+	return &entry
+}
+
+// AddType adds a type to a var of this memory. A type the var already has is not added again.
+func (mem *VarMemory) AddType(name string, tp parser.FcVarType) (*VarMemoryEntry, error) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	entry, ok := mem.entries[name]
+	if !ok {
+		return nil, &MemoryErr{fmt.Errorf("%s is not a var", name)}
+	}
+
+	for _, cur := range entry.Types {
+		if cur.String() == tp.String() {
+			return &entry, nil
+		}
+	}
+
+	// entries returned before share the old slice with their readers
+	entry.Types = append(append([]parser.FcVarType{}, entry.Types...), tp)
+	mem.entries[name] = entry
+	return &entry, nil
+}
+
+// Traverse visits entries in the order of their names.
+func (mem *VarMemory) Traverse(visit func(name string, entry *VarMemoryEntry) error) error {
+	names, entries := sortedEntries(&mem.mu, mem.entries)
+	for i, name := range names {
+		if err := visit(name, &entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (mem *PropMemory) Get(s string) (*PropMemoryEntry, bool) {
-	// TODO
-	return nil, false
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+
+	ret, ok := mem.entries[s]
+	if !ok {
+		return nil, false
+	}
+	return &ret, true
+}
+
+func (mem *PropMemory) Set(decl *parser.PropDecl) (*PropMemoryEntry, error) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	toStore := PropMemoryEntry{
+		decl.Tp,
+		[]parser.FcPropType{decl.Tp},
+		*decl,
+		nil,
+	}
+	mem.entries[decl.Name] = toStore
+
+	return &toStore, nil
+}
+
+// SetExist stores the prop which stmt declares with the members and facts it provides.
+func (mem *PropMemory) SetExist(stmt *parser.DefExistStmt) (*PropMemoryEntry, error) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	toStore := PropMemoryEntry{
+		stmt.Decl.Tp,
+		[]parser.FcPropType{stmt.Decl.Tp},
+		stmt.Decl,
+		stmt,
+	}
+	mem.entries[stmt.Decl.Name] = toStore
+
+	return &toStore, nil
+}
+
+// Traverse visits entries in the order of their names.
+func (mem *PropMemory) Traverse(visit func(name string, entry *PropMemoryEntry) error) error {
+	names, entries := sortedEntries(&mem.mu, mem.entries)
+	for i, name := range names {
+		if err := visit(name, &entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (mem *FnMemory) Get(s string) (*FnMemEntry, bool) {
-	//TODO
-	return nil, false
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+
+	ret, ok := mem.entries[s]
+	if !ok {
+		return nil, false
+	}
+	return &ret, true
+}
+
+func (mem *FnMemory) Set(decl *parser.FcFnDecl) (*FnMemEntry, error) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	toStore := FnMemEntry{
+		decl.Tp,
+		[]parser.FcFnType{decl.Tp},
+		*decl,
+	}
+	mem.entries[decl.Name] = toStore
+
+	return &toStore, nil
+}
+
+// Traverse visits entries in the order of their names.
+func (mem *FnMemory) Traverse(visit func(name string, entry *FnMemEntry) error) error {
+	names, entries := sortedEntries(&mem.mu, mem.entries)
+	for i, name := range names {
+		if err := visit(name, &entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (mem *AliasMemory) Get(s string) (*AliasMemEntry, bool) {
-	// TODO
-	return nil, false
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+
+	ret, ok := mem.entries[s]
+	if !ok {
+		return nil, false
+	}
+	return &ret, true
+}
+
+func (mem *AliasMemory) Set(stmt *parser.DefAliasStmt) (*AliasMemEntry, error) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	toStore := AliasMemEntry{
+		&[]string{stmt.PreviousName},
+	}
+	mem.entries[stmt.NewName] = toStore
+
+	return &toStore, nil
+}
+
+// Traverse visits entries in the order of their names.
+func (mem *AliasMemory) Traverse(visit func(name string, entry *AliasMemEntry) error) error {
+	names, entries := sortedEntries(&mem.mu, mem.entries)
+	for i, name := range names {
+		if err := visit(name, &entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mem *FcVarTypeMemory) Get(tp parser.FcVarType) ([]string, bool) {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+
+	ret, ok := mem.entries[tp.String()]
+	return ret, ok
+}
+
+// Add records that the var has the type. Adding the same pair twice has no effect.
+func (mem *FcVarTypeMemory) Add(tp parser.FcVarType, varName string) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	key := tp.String()
+	for _, name := range mem.entries[key] {
+		if name == varName {
+			return
+		}
+	}
+	mem.entries[key] = append(append([]string{}, mem.entries[key]...), varName)
+}
+
+// GetTypesOf returns the types of the var in this memory, in the order of their names.
+func (mem *FcVarTypeMemory) GetTypesOf(varName string) []string {
+	ret := []string{}
+	types, vars := sortedEntries(&mem.mu, mem.entries)
+	for i, tp := range types {
+		for _, name := range vars[i] {
+			if name == varName {
+				ret = append(ret, tp)
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// Traverse visits types in the order of their names, with the vars in the order they were added.
+func (mem *FcVarTypeMemory) Traverse(visit func(tp string, vars []string) error) error {
+	types, vars := sortedEntries(&mem.mu, mem.entries)
+	for i, tp := range types {
+		if err := visit(tp, vars[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mem *TypeMemory) Get(s string) (*TypeMemEntry, bool) {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+
+	ret, ok := mem.entries[s]
+	if !ok {
+		return nil, false
+	}
+	return &ret, true
+}
+
+func (mem *TypeMemory) Set(name string, extends []string) (*TypeMemEntry, error) {
+	mem.mu.Lock()
+	defer mem.mu.Unlock()
+
+	toStore := TypeMemEntry{extends}
+	mem.entries[name] = toStore
+
+	return &toStore, nil
+}
+
+// Traverse visits entries in the order of their names.
+func (mem *TypeMemory) Traverse(visit func(name string, entry *TypeMemEntry) error) error {
+	names, entries := sortedEntries(&mem.mu, mem.entries)
+	for i, name := range names {
+		if err := visit(name, &entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// sortedEntries copies the entries of m in the order of their keys, under the read lock of the memory which owns m.
+func sortedEntries[K ~string, V any](mu *sync.RWMutex, m map[K]V) ([]K, []V) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	keys := sortedKeys(m)
+	values := make([]V, len(keys))
+	for i, k := range keys {
+		values[i] = m[k]
+	}
+	return keys, values
+}
+
+func (mem *VarMemory) Len() int {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	return len(mem.entries)
+}
+
+func (mem *PropMemory) Len() int {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	return len(mem.entries)
+}
+
+func (mem *FnMemory) Len() int {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	return len(mem.entries)
+}
+
+func (mem *AliasMemory) Len() int {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	return len(mem.entries)
+}
+
+func (mem *TypeMemory) Len() int {
+	mem.mu.RLock()
+	defer mem.mu.RUnlock()
+	return len(mem.entries)
 }
@@ -2,6 +2,7 @@ package litexmemory
 
 import (
 	parser "golitex/litex_parser"
+	"sync"
 )
 
 // type FnPropParaMemTree struct {
@@ -14,20 +15,25 @@ type VarMemoryEntry struct {
 }
 
 type PropMemory struct {
-	Entires map[string]PropMemoryEntry
+	mu      sync.RWMutex
+	entries map[string]PropMemoryEntry
 }
 
 func NewPropMemory() *PropMemory {
-	return &PropMemory{map[string]PropMemoryEntry{}}
+	return &PropMemory{entries: map[string]PropMemoryEntry{}}
 }
 
 type PropMemoryEntry struct {
 	Tp    parser.FcPropType
 	Types []parser.FcPropType
 	Decl  parser.PropDecl
+	Exist *parser.DefExistStmt // the members and facts an exist prop provides, nil for other props
 }
 
-type FnMemory struct{ entries map[string]FnMemEntry }
+type FnMemory struct {
+	mu      sync.RWMutex
+	entries map[string]FnMemEntry
+}
 
 func NewFnMemory() *FnMemory {
 	return &FnMemory{entries: map[string]FnMemEntry{}}
@@ -39,20 +45,40 @@ type FnMemEntry struct {
 	Decl  parser.FcFnDecl
 }
 
-type AliasMemory struct{ entries map[string]AliasMemEntry }
+type AliasMemory struct {
+	mu      sync.RWMutex
+	entries map[string]AliasMemEntry
+}
 
 func NewAliasMemory() *AliasMemory {
-	return &AliasMemory{map[string]AliasMemEntry{}}
+	return &AliasMemory{entries: map[string]AliasMemEntry{}}
 }
 
 type AliasMemEntry struct {
 	Values *[]string
 }
 
-type FcVarTypeMemory struct{ entries map[string][]parser.FcVarType }
+// FcVarTypeMemory is the reverse index of the types of vars: it maps a type to the vars which have the type directly
+type FcVarTypeMemory struct {
+	mu      sync.RWMutex
+	entries map[string][]string
+}
 
 func NewFcVarTypeMemory() *FcVarTypeMemory {
-	return &FcVarTypeMemory{entries: map[string][]parser.FcVarType{}}
+	return &FcVarTypeMemory{entries: map[string][]string{}}
+}
+
+type TypeMemory struct {
+	mu      sync.RWMutex
+	entries map[string]TypeMemEntry
+}
+
+func NewTypeMemory() *TypeMemory {
+	return &TypeMemory{entries: map[string]TypeMemEntry{}}
+}
+
+type TypeMemEntry struct {
+	Extends []string // names of the types which the type is a subset of
 }
 
 type MemoryErr struct {
@@ -63,8 +89,11 @@ func (e *MemoryErr) Error() string {
 	return e.err.Error()
 }
 
-type VarMemory struct{ KVs map[string]VarMemoryEntry }
+type VarMemory struct {
+	mu      sync.RWMutex
+	entries map[string]VarMemoryEntry
+}
 
 func NewVarMemory() *VarMemory {
-	return &VarMemory{KVs: map[string]VarMemoryEntry{}}
+	return &VarMemory{entries: map[string]VarMemoryEntry{}}
 }
@@ -3,6 +3,11 @@ package litexmemory
 import (
 	"errors"
 	"fmt"
+	parser "golitex/litex_parser"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 )
 
@@ -41,3 +46,283 @@ func TestRedBlackTree(t *testing.T) {
 		return nil
 	})
 }
+
+func newTestUniFact(varNames []string, thenFacts ...parser.SpecFactStmt) *parser.BlockForallStmt {
+	varParams := []parser.StrTypePair{}
+	for _, name := range varNames {
+		varParams = append(varParams, parser.StrTypePair{Var: name, Type: parser.FcVarType{PackageName: "", Value: parser.FcVarTypeStrValue("G")}})
+	}
+	return &parser.BlockForallStmt{TypeParams: []parser.TypeConceptPair{}, VarParams: varParams, Cond: []parser.FactStmt{}, Then: thenFacts}
+}
+
+func newTestFnRetValue(name string, params ...parser.Fc) *parser.FcFnRetValue {
+	return &parser.FcFnRetValue{FnName: parser.FcStr{Value: name}, TypeParamsVarParamsPairs: []parser.TypeParamsAndParamsPair{{TypeParams: []parser.TypeVarStr{}, VarParams: params}}}
+}
+
+func newTestRelationFact(opt string, vars ...parser.Fc) *parser.RelationFactStmt {
+	return &parser.RelationFactStmt{IsTrue: true, Vars: vars, Opt: parser.FcStr{Value: opt}}
+}
+
+func TestUniFactCandidates(t *testing.T) {
+	x, y, a, b, one := parser.FcStr{Value: "x"}, parser.FcStr{Value: "y"}, parser.FcStr{Value: "a"}, parser.FcStr{Value: "b"}, parser.FcStr{Value: "1"}
+
+	mem := NewUniFactMemory()
+	facts := []*parser.BlockForallStmt{
+		newTestUniFact([]string{"x"}, newTestRelationFact("<", x, newTestFnRetValue("+", x, one))),
+		newTestUniFact([]string{"x", "y"}, newTestRelationFact("<", x, newTestFnRetValue("+", y, one))),
+		newTestUniFact([]string{"x"}, newTestRelationFact("=", x, x)),
+		newTestUniFact([]string{"x"}, &parser.FuncFactStmt{IsTrue: true, Fc: newTestFnRetValue("p", x)}),
+		newTestUniFact([]string{"p", "x"}, &parser.FuncFactStmt{IsTrue: true, Fc: newTestFnRetValue("p", x)}),
+	}
+	for _, fact := range facts {
+		if err := mem.NewFact(fact, FactProvenance{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// each candidate is given by its substitution, e.g. x=a y=b
+	testCases := []struct {
+		goal parser.SpecFactStmt
+		want []string
+	}{
+		{newTestRelationFact("<", a, newTestFnRetValue("+", a, one)), []string{"x=a", "x=a y=a"}},
+		{newTestRelationFact("<", a, newTestFnRetValue("+", b, one)), []string{"x=a y=b"}},
+		{newTestRelationFact("<", a, newTestFnRetValue("-", a, one)), []string{}},
+		{newTestRelationFact("=", a, a), []string{"x=a"}},
+		{newTestRelationFact("=", a, b), []string{}},
+		{&parser.FuncFactStmt{IsTrue: true, Fc: newTestFnRetValue("p", a)}, []string{"p=p x=a", "x=a"}},
+		{&parser.FuncFactStmt{IsTrue: true, Fc: newTestFnRetValue("q", a)}, []string{"p=q x=a"}},
+		{&parser.FuncFactStmt{IsTrue: false, Fc: newTestFnRetValue("p", a)}, []string{}},
+	}
+
+	for _, testCase := range testCases {
+		candidates, err := mem.GetCandidates(testCase.goal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := []string{}
+		for _, candidate := range candidates {
+			subst := []string{}
+			for name, value := range candidate.VarSubst {
+				subst = append(subst, fmt.Sprintf("%s=%s", name, value))
+			}
+			sort.Strings(subst)
+			got = append(got, strings.Join(subst, " "))
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, testCase.want) {
+			t.Fatalf("expected the candidates %v for %v, got %v", testCase.want, testCase.goal, got)
+		}
+	}
+}
+
+// a synthetic library of forall x G, y G: x < f_i(y), x < f_i(y) + 1, ...
+func newTestUniFactLibrary(size int) *UniFactMemory {
+	mem := NewUniFactMemory()
+	x, y, one := parser.FcStr{Value: "x"}, parser.FcStr{Value: "y"}, parser.FcStr{Value: "1"}
+	for i := 0; i < size; i++ {
+		fn := newTestFnRetValue(fmt.Sprintf("f%d", i/4), y)
+		var right parser.Fc = fn
+		switch i % 4 {
+		case 1:
+			right = newTestFnRetValue("+", fn, one)
+		case 2:
+			right = newTestFnRetValue("*", fn, y)
+		case 3:
+			right = newTestFnRetValue("+", x, fn)
+		}
+		if err := mem.NewFact(newTestUniFact([]string{"x", "y"}, newTestRelationFact("<", x, right)), FactProvenance{}); err != nil {
+			panic(err)
+		}
+	}
+	return mem
+}
+
+func linearScanUniFactCandidates(mem *UniFactMemory, goal parser.SpecFactStmt) ([]UniFactCandidate, error) {
+	ret := []UniFactCandidate{}
+	for _, entry := range mem.entries {
+		for i := range entry.Facts {
+			fact := &entry.Facts[i]
+			for thenIndex, thenFact := range *fact.then {
+				matcher := newUniFactMatcher(fact)
+				ok, err := matcher.matchSpecFact(thenFact, goal)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					ret = append(ret, UniFactCandidate{fact, thenIndex, matcher.varSubst, matcher.typeSubst})
+				}
+			}
+		}
+	}
+	return ret, nil
+}
+
+func TestUniFactCandidatesAgreeWithLinearScan(t *testing.T) {
+	mem := newTestUniFactLibrary(400)
+	goal := newTestRelationFact("<", parser.FcStr{Value: "a"}, newTestFnRetValue("+", newTestFnRetValue("f7", parser.FcStr{Value: "b"}), parser.FcStr{Value: "1"}))
+
+	indexed, err := mem.GetCandidates(goal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanned, err := linearScanUniFactCandidates(mem, goal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexed) != 1 || len(scanned) != 1 {
+		t.Fatalf("expected 1 candidate, got %d indexed and %d scanned", len(indexed), len(scanned))
+	}
+}
+
+func benchmarkUniFactCandidates(b *testing.B, size int, retrieve func(*UniFactMemory, parser.SpecFactStmt) ([]UniFactCandidate, error)) {
+	mem := newTestUniFactLibrary(size)
+	goal := newTestRelationFact("<", parser.FcStr{Value: "a"}, newTestFnRetValue("+", newTestFnRetValue(fmt.Sprintf("f%d", size/8), parser.FcStr{Value: "b"}), parser.FcStr{Value: "1"}))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := retrieve(mem, goal); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUniFactCandidatesIndexed1000(b *testing.B) {
+	benchmarkUniFactCandidates(b, 1000, (*UniFactMemory).GetCandidates)
+}
+
+func BenchmarkUniFactCandidatesIndexed10000(b *testing.B) {
+	benchmarkUniFactCandidates(b, 10000, (*UniFactMemory).GetCandidates)
+}
+
+func BenchmarkUniFactCandidatesLinearScan1000(b *testing.B) {
+	benchmarkUniFactCandidates(b, 1000, linearScanUniFactCandidates)
+}
+
+func BenchmarkUniFactCandidatesLinearScan10000(b *testing.B) {
+	benchmarkUniFactCandidates(b, 10000, linearScanUniFactCandidates)
+}
+
+func TestFcInternTable(t *testing.T) {
+	table := NewFcInternTable()
+	a, b := parser.FcStr{Value: "a"}, parser.FcStr{Value: "b"}
+
+	sum := newTestFnRetValue("+", newTestFnRetValue("f", a), b)
+	sameSum := newTestFnRetValue("+", newTestFnRetValue("f", a), b)
+	chain := parser.FcMemChain{Members: []parser.Fc{a, newTestFnRetValue("f", a)}}
+
+	sumID, err := table.Intern(sum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sameSumID, err := table.Intern(sameSum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sumID != sameSumID {
+		t.Fatalf("expected %v and %v to have the same id", sum, sameSum)
+	}
+
+	chainID, err := table.Intern(&chain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chainID == sumID {
+		t.Fatalf("expected %v and %v to have different ids", sum, &chain)
+	}
+
+	// a, b, f(a), +(f(a), b) and the chain
+	if table.Len() != 5 {
+		t.Fatalf("expected 5 interned terms, got %d", table.Len())
+	}
+
+	sumTerm, _ := table.Term(sumID)
+	chainTerm, _ := table.Term(chainID)
+	if sumTerm.(*parser.FcFnRetValue).TypeParamsVarParamsPairs[0].VarParams[0] != chainTerm.(*parser.FcMemChain).Members[1] {
+		t.Fatalf("expected f(a) to be shared")
+	}
+
+	if _, ok := table.Get(newTestFnRetValue("g", a)); ok {
+		t.Fatalf("expected g(a) not to be interned")
+	}
+
+	// Equal only reads the table
+	for _, c := range []struct {
+		left, right parser.Fc
+		want        bool
+	}{
+		{sum, sameSum, true},
+		{sum, newTestFnRetValue("g", a), false},
+		{newTestFnRetValue("g", a), newTestFnRetValue("g", a), true},
+		{newTestFnRetValue("g", a), newTestFnRetValue("g", b), false},
+	} {
+		if equal, err := table.Equal(c.left, c.right); err != nil || equal != c.want {
+			t.Fatalf("expected Equal(%s, %s) to be %v, %v", c.left, c.right, c.want, err)
+		}
+	}
+	if table.Len() != 5 {
+		t.Fatalf("expected Equal not to intern terms, got %d terms", table.Len())
+	}
+}
+
+// Run with go test -race to check that readers and the writer of a memory do not race.
+func TestConcurrentFactMemoryAccess(t *testing.T) {
+	specMem := NewSpecFactMemory()
+	uniMem := NewUniFactMemory()
+	varMem := NewVarMemory()
+	x := parser.FcStr{Value: "x"}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			a := parser.FcStr{Value: fmt.Sprintf("a%d", i)}
+			if err := specMem.NewFact(newTestRelationFact("<", a, x), FactProvenance{TopStmtIndex: i}); err != nil {
+				errs <- err
+				return
+			}
+			if err := uniMem.NewFact(newTestUniFact([]string{"y"}, newTestRelationFact("<", parser.FcStr{Value: "y"}, a)), FactProvenance{TopStmtIndex: i}); err != nil {
+				errs <- err
+				return
+			}
+			varMem.Set(&parser.FcVarDeclPair{Var: a.Value, Tp: parser.FcVarType{PackageName: "", Value: parser.FcVarTypeStrValue("G")}})
+		}
+	}()
+
+	for reader := 0; reader < 8; reader++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				a := parser.FcStr{Value: fmt.Sprintf("a%d", i)}
+				if _, _, err := specMem.GetProvenance(newTestRelationFact("<", a, x)); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := uniMem.GetCandidates(newTestRelationFact("<", x, a)); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := specMem.GetFactsAbout(a); err != nil {
+					errs <- err
+					return
+				}
+				uniMem.Len()
+				varMem.Get(a.Value)
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	if specMem.Len() != 200 || uniMem.Len() != 200 || varMem.Len() != 200 {
+		t.Fatalf("expected 200 facts and vars, got %d, %d, %d", specMem.Len(), uniMem.Len(), varMem.Len())
+	}
+}
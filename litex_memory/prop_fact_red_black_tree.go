@@ -168,3 +168,30 @@ func (t *RedBlackTree) InOrderTraversal(node *Node, visit func(key interface{})
 	}
 	return nil
 }
+
+// Search returns the first inserted key which compares equal to the given key
+func (t *RedBlackTree) Search(key interface{}) (interface{}, bool, error) {
+	// equal keys are inserted to the right, so the leftmost equal key is the first inserted one
+	var found *Node
+	node := t.root
+	for node != nil {
+		compareResult, err := t.compare(key, node.key)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if compareResult < 0 {
+			node = node.left
+		} else if compareResult > 0 {
+			node = node.right
+		} else {
+			found = node
+			node = node.left
+		}
+	}
+
+	if found == nil {
+		return nil, false, nil
+	}
+	return found.key, true, nil
+}
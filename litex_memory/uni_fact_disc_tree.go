@@ -0,0 +1,377 @@
+package litexmemory
+
+import (
+	"fmt"
+	parser "golitex/litex_parser"
+	"strings"
+)
+
+// The discrimination tree indexes universal facts by the shape of their then facts.
+// Every then fact is flattened in preorder into a sequence of keys. Parameters bound by the forall
+// become wildcards, so a path from the root to a leaf describes every goal the then fact might unify with.
+
+type discTreeKey string
+
+const discTreeWildcard discTreeKey = "*"
+
+// forall facts whose then fact uses a bound prop parameter (e.g. forall p prop(x G): $p(x)) can match goals of any prop name
+const anyPropName PropName = "*"
+
+type discTreeNode struct {
+	children map[discTreeKey]*discTreeNode
+	leaves   []discTreeLeaf
+}
+
+type discTreeLeaf struct {
+	factIndex int
+	thenIndex int
+}
+
+// end is the index right after the subterm that starts at key, so that a wildcard can skip the whole subterm of a goal.
+type discTreeFlatKey struct {
+	key discTreeKey
+	end int
+}
+
+func newDiscTreeNode() *discTreeNode {
+	return &discTreeNode{children: map[discTreeKey]*discTreeNode{}}
+}
+
+func (node *discTreeNode) insert(keys []discTreeFlatKey, leaf discTreeLeaf) {
+	cur := node
+	for _, k := range keys {
+		next, ok := cur.children[k.key]
+		if !ok {
+			next = newDiscTreeNode()
+			cur.children[k.key] = next
+		}
+		cur = next
+	}
+	cur.leaves = append(cur.leaves, leaf)
+}
+
+func (node *discTreeNode) retrieve(goal []discTreeFlatKey, pos int, visit func(leaf discTreeLeaf) error) error {
+	if pos == len(goal) {
+		for _, leaf := range node.leaves {
+			if err := visit(leaf); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if next, ok := node.children[goal[pos].key]; ok {
+		if err := next.retrieve(goal, pos+1, visit); err != nil {
+			return err
+		}
+	}
+
+	if next, ok := node.children[discTreeWildcard]; ok {
+		if err := next.retrieve(goal, goal[pos].end, visit); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// discTreeFlattener turns a spec fact into keys. Goals are flattened with no bound parameters.
+type discTreeFlattener struct {
+	boundVars  map[string]struct{}
+	boundTypes map[parser.TypeVarStr]struct{}
+	keys       []discTreeFlatKey
+}
+
+func newDiscTreeFlattener(typeParams *[]parser.TypeConceptPair, varParams *[]parser.StrTypePair) *discTreeFlattener {
+	flattener := &discTreeFlattener{map[string]struct{}{}, map[parser.TypeVarStr]struct{}{}, []discTreeFlatKey{}}
+
+	if typeParams != nil {
+		for _, pair := range *typeParams {
+			flattener.boundTypes[pair.Var] = struct{}{}
+		}
+	}
+
+	if varParams != nil {
+		for _, pair := range *varParams {
+			flattener.boundVars[pair.Var] = struct{}{}
+		}
+	}
+
+	return flattener
+}
+
+func (f *discTreeFlattener) isBoundVar(s string) bool {
+	_, ok := f.boundVars[s]
+	return ok
+}
+
+func (f *discTreeFlattener) isBoundType(s parser.TypeVarStr) bool {
+	_, ok := f.boundTypes[s]
+	return ok
+}
+
+func (f *discTreeFlattener) push(key discTreeKey) int {
+	f.keys = append(f.keys, discTreeFlatKey{key, len(f.keys) + 1})
+	return len(f.keys) - 1
+}
+
+func (f *discTreeFlattener) flattenSpecFact(fact parser.SpecFactStmt) error {
+	switch fact := fact.(type) {
+	case *parser.FuncFactStmt:
+		start := f.push(discTreeKey(fmt.Sprintf("$:%v", fact.IsTrue)))
+		if err := f.flattenFc(fact.Fc); err != nil {
+			return err
+		}
+		f.keys[start].end = len(f.keys)
+		return nil
+	case *parser.RelationFactStmt:
+		start := f.push(discTreeKey(fmt.Sprintf("rel:%v:%s:%d", fact.IsTrue, fact.Opt, len(fact.Vars))))
+		for _, v := range fact.Vars {
+			if err := f.flattenFc(v); err != nil {
+				return err
+			}
+		}
+		f.keys[start].end = len(f.keys)
+		return nil
+	}
+
+	return fmt.Errorf("unknown SpecFactStmt type: %T", fact)
+}
+
+func (f *discTreeFlattener) flattenFc(fc parser.Fc) error {
+	switch fc := fc.(type) {
+	case parser.FcStr:
+		if f.isBoundVar(fc.Value) {
+			f.push(discTreeWildcard)
+		} else {
+			f.push(discTreeKey("str:" + fc.Value))
+		}
+		return nil
+
+	case *parser.FcFnRetValue:
+		// a bound fn parameter might be instantiated with anything, so the whole subterm is a wildcard
+		if f.isBoundVar(fc.FnName.Value) {
+			f.push(discTreeWildcard)
+			return nil
+		}
+
+		start := f.push(discTreeKey("fn:" + fc.FnName.Value + fcFnRetValueShape(fc)))
+		for _, pair := range fc.TypeParamsVarParamsPairs {
+			for _, tp := range pair.TypeParams {
+				if f.isBoundType(tp) {
+					f.push(discTreeWildcard)
+				} else {
+					f.push(discTreeKey("type:" + string(tp)))
+				}
+			}
+			for _, v := range pair.VarParams {
+				if err := f.flattenFc(v); err != nil {
+					return err
+				}
+			}
+		}
+		f.keys[start].end = len(f.keys)
+		return nil
+
+	case *parser.FcMemChain:
+		start := f.push(discTreeKey(fmt.Sprintf("chain:%d", len(fc.Members))))
+		for _, member := range fc.Members {
+			if err := f.flattenFc(member); err != nil {
+				return err
+			}
+		}
+		f.keys[start].end = len(f.keys)
+		return nil
+
+	case *parser.FcLambdaFn, *parser.FcLambdaProp:
+		// a lambda in a universal fact may mention its parameters, so it is a wildcard and is
+		// compared by the matcher; a lambda in a goal is a single key
+		if len(f.boundVars) != 0 {
+			f.push(discTreeWildcard)
+		} else {
+			f.push(discTreeKey("lambda:" + parser.CanonicalFc(fc).String()))
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unknown Fc type: %T", fc)
+}
+
+// f[G](a, b)(c) has shape [1](2)[0](1)
+func fcFnRetValueShape(fc *parser.FcFnRetValue) string {
+	var builder strings.Builder
+	for _, pair := range fc.TypeParamsVarParamsPairs {
+		fmt.Fprintf(&builder, "[%d](%d)", len(pair.TypeParams), len(pair.VarParams))
+	}
+	return builder.String()
+}
+
+func getSpecFactPropName(fact parser.SpecFactStmt) (PropName, error) {
+	switch fact := fact.(type) {
+	case *parser.FuncFactStmt:
+		return getFcPropName(fact.Fc)
+	case *parser.RelationFactStmt:
+		return PropName(fact.Opt.String()), nil
+	}
+
+	return "", fmt.Errorf("unknown SpecFactStmt type: %T", fact)
+}
+
+func getFcPropName(fc parser.Fc) (PropName, error) {
+	switch fc := fc.(type) {
+	case parser.FcStr:
+		return PropName(fc.Value), nil
+	case *parser.FcFnRetValue:
+		return PropName(fc.FnName.Value), nil
+	case *parser.FcMemChain:
+		names := []string{}
+		for _, member := range fc.Members {
+			name, err := getFcPropName(member)
+			if err != nil {
+				return "", err
+			}
+			names = append(names, string(name))
+		}
+		return PropName(strings.Join(names, ".")), nil
+	}
+
+	return "", fmt.Errorf("unknown Fc type: %T", fc)
+}
+
+// uniFactMatcher checks whether a then fact unifies with a goal, binding parameters of the forall consistently.
+// Parameters which appear more than once, such as x in forall x G: x = x, are what the discrimination tree cannot check.
+type uniFactMatcher struct {
+	flattener *discTreeFlattener
+	varSubst  map[string]parser.Fc
+	typeSubst map[parser.TypeVarStr]parser.TypeVarStr
+}
+
+func newUniFactMatcher(fact *UniMemFact) *uniFactMatcher {
+	return &uniFactMatcher{
+		newDiscTreeFlattener(fact.typeParams, fact.varParams),
+		map[string]parser.Fc{},
+		map[parser.TypeVarStr]parser.TypeVarStr{},
+	}
+}
+
+func (m *uniFactMatcher) matchSpecFact(pattern parser.SpecFactStmt, goal parser.SpecFactStmt) (bool, error) {
+	switch pattern := pattern.(type) {
+	case *parser.FuncFactStmt:
+		goal, ok := goal.(*parser.FuncFactStmt)
+		if !ok || pattern.IsTrue != goal.IsTrue {
+			return false, nil
+		}
+		return m.matchFc(pattern.Fc, goal.Fc)
+
+	case *parser.RelationFactStmt:
+		goal, ok := goal.(*parser.RelationFactStmt)
+		if !ok || pattern.IsTrue != goal.IsTrue || len(pattern.Vars) != len(goal.Vars) {
+			return false, nil
+		}
+		if comp, err := compareFc(pattern.Opt, goal.Opt); comp != 0 || err != nil {
+			return false, err
+		}
+		return m.matchFcSlice(pattern.Vars, goal.Vars)
+	}
+
+	return false, fmt.Errorf("unknown SpecFactStmt type: %T", pattern)
+}
+
+func (m *uniFactMatcher) bindVar(name string, goal parser.Fc) (bool, error) {
+	bound, ok := m.varSubst[name]
+	if !ok {
+		m.varSubst[name] = goal
+		return true, nil
+	}
+
+	comp, err := compareFc(bound, goal)
+	return comp == 0, err
+}
+
+func (m *uniFactMatcher) matchFc(pattern parser.Fc, goal parser.Fc) (bool, error) {
+	switch pattern := pattern.(type) {
+	case parser.FcStr:
+		if m.flattener.isBoundVar(pattern.Value) {
+			return m.bindVar(pattern.Value, goal)
+		}
+		goal, ok := goal.(parser.FcStr)
+		return ok && goal.Value == pattern.Value, nil
+
+	case *parser.FcFnRetValue:
+		goal, ok := goal.(*parser.FcFnRetValue)
+		if !ok || len(pattern.TypeParamsVarParamsPairs) != len(goal.TypeParamsVarParamsPairs) {
+			return false, nil
+		}
+
+		if m.flattener.isBoundVar(pattern.FnName.Value) {
+			if ok, err := m.bindVar(pattern.FnName.Value, goal.FnName); !ok || err != nil {
+				return false, err
+			}
+		} else if pattern.FnName.Value != goal.FnName.Value {
+			return false, nil
+		}
+
+		for i, pair := range pattern.TypeParamsVarParamsPairs {
+			goalPair := goal.TypeParamsVarParamsPairs[i]
+			if ok := m.matchTypeParams(pair.TypeParams, goalPair.TypeParams); !ok {
+				return false, nil
+			}
+			if ok, err := m.matchFcSlice(pair.VarParams, goalPair.VarParams); !ok || err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+
+	case *parser.FcMemChain:
+		goal, ok := goal.(*parser.FcMemChain)
+		if !ok {
+			return false, nil
+		}
+		return m.matchFcSlice(pattern.Members, goal.Members)
+
+	case *parser.FcLambdaFn, *parser.FcLambdaProp:
+		// parameters of the universal fact in a lambda are not bound by matching
+		comp, err := compareFc(pattern, goal)
+		return comp == 0, err
+	}
+
+	return false, fmt.Errorf("unknown Fc type: %T", pattern)
+}
+
+func (m *uniFactMatcher) matchFcSlice(patterns []parser.Fc, goals []parser.Fc) (bool, error) {
+	if len(patterns) != len(goals) {
+		return false, nil
+	}
+
+	for i, pattern := range patterns {
+		if ok, err := m.matchFc(pattern, goals[i]); !ok || err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func (m *uniFactMatcher) matchTypeParams(patterns []parser.TypeVarStr, goals []parser.TypeVarStr) bool {
+	if len(patterns) != len(goals) {
+		return false
+	}
+
+	for i, pattern := range patterns {
+		if !m.flattener.isBoundType(pattern) {
+			if pattern != goals[i] {
+				return false
+			}
+			continue
+		}
+
+		bound, ok := m.typeSubst[pattern]
+		if !ok {
+			m.typeSubst[pattern] = goals[i]
+		} else if bound != goals[i] {
+			return false
+		}
+	}
+
+	return true
+}
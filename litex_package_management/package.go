@@ -1,18 +1,34 @@
 package litexpackage
 
-var ImportedPackDict = PackDict{map[string]Pack{}}
+import "sync"
 
+// PackDict holds the packages imported by an env. It is safe to use from several goroutines.
 type PackDict struct {
+	mu      sync.RWMutex
 	entries map[string]Pack
 }
 
 type Pack struct {
 }
 
-func (p PackDict) Get(s string) (*Pack, bool) {
+func NewPackDict() *PackDict {
+	return &PackDict{entries: map[string]Pack{}}
+}
+
+func (p *PackDict) Get(s string) (*Pack, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	pack, ok := p.entries[s]
 	if !ok {
 		return nil, false
 	}
 	return &pack, true
 }
+
+func (p *PackDict) Set(s string, pack Pack) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.entries[s] = pack
+}
@@ -0,0 +1,303 @@
+package litexparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// The AST encodes to JSON losslessly. A struct encodes to an object of its exported fields. Every
+// value held by an interface, like a Stmt or an Fc, is tagged by "kind", the name of its Go type:
+//
+//	{"kind": "FuncFactStmt", "IsTrue": true, "Fc": {"kind": "FcStr", "Value": "p", "Span": {...}}, "Span": {...}}
+//
+// Values of kinds which are not structs, like FcVarTypeStrValue, are stored under "value".
+// A nil slice encodes to null and an empty slice to [], so decoding gives back the same tree.
+
+// nodeKinds lists all types that are stored in AST interfaces.
+var nodeKinds = map[string]reflect.Type{}
+
+func init() {
+	for _, node := range []any{
+		&DefVarStmt{}, &DefConceptStmt{}, &DefTypeStmt{}, &DefPropStmt{}, &DefFnStmt{},
+		&BlockForallStmt{}, &RelationFactStmt{}, &FuncFactStmt{}, &ClaimProveStmt{}, &DefAliasStmt{},
+		&KnowStmt{}, &DefExistStmt{}, &HaveStmt{}, &DefMemberStmt{}, &DefTypeMemberStmt{},
+		&ClaimProveByContradictStmt{}, &AxiomStmt{}, &ThmStmt{}, &IfFactStmt{}, &DefNotationStmt{},
+		FcStr{}, &FcFnRetValue{}, &FcMemChain{}, &FcLambdaFn{}, &FcLambdaProp{},
+		FcVarType{}, &FcFnType{}, &FcPropType{}, &UndefinedFnType{}, &UndefinedVarType{}, &UndefinedPropType{},
+		&FcVarDecl{}, &FcFnDecl{}, &PropDecl{},
+		FcVarTypeStrValue(""), &FcVarTypeFuncValue{},
+	} {
+		nodeKinds[nodeKind(reflect.TypeOf(node))] = reflect.TypeOf(node)
+	}
+}
+
+// singletons are decoded to the shared instance instead of a new value.
+var singletons = map[string]any{
+	"UndefinedFnType":   undefinedFnTypeInstance,
+	"UndefinedVarType":  undefinedVarTypeInstance,
+	"UndefinedPropType": undefinedPropTypeInstance,
+}
+
+var spanType = reflect.TypeOf(Span{})
+
+func nodeKind(t reflect.Type) string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+func (stmt TopStmt) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	if err := encodeJSON(&b, reflect.ValueOf(struct {
+		Stmt  Stmt
+		IsPub bool
+		File  string
+		Line  int
+		Index int
+	}(stmt))); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (stmt *TopStmt) UnmarshalJSON(data []byte) error {
+	return decodeJSON(data, reflect.ValueOf(stmt).Elem(), true)
+}
+
+// MarshalNode encodes an AST node, like a Stmt, an Fc or a type, to JSON with its kind.
+func MarshalNode(node any) ([]byte, error) {
+	if node == nil {
+		return []byte("null"), nil
+	}
+	var b bytes.Buffer
+	if err := encodeNode(&b, reflect.ValueOf(node)); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// UnmarshalNode decodes a node encoded by MarshalNode.
+func UnmarshalNode(data []byte) (any, error) {
+	var node any
+	if err := decodeJSON(data, reflect.ValueOf(&node).Elem(), false); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// UnmarshalStmt decodes a statement encoded by MarshalNode.
+func UnmarshalStmt(data []byte) (Stmt, error) {
+	var stmt Stmt
+	if err := decodeJSON(data, reflect.ValueOf(&stmt).Elem(), false); err != nil {
+		return nil, err
+	}
+	return stmt, nil
+}
+
+// UnmarshalFc decodes an Fc encoded by MarshalNode.
+func UnmarshalFc(data []byte) (Fc, error) {
+	var fc Fc
+	if err := decodeJSON(data, reflect.ValueOf(&fc).Elem(), false); err != nil {
+		return nil, err
+	}
+	return fc, nil
+}
+
+func encodeJSON(b *bytes.Buffer, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			b.WriteString("null")
+			return nil
+		}
+		return encodeNode(b, v.Elem())
+	case reflect.Pointer:
+		if v.IsNil() {
+			b.WriteString("null")
+			return nil
+		}
+		return encodeJSON(b, v.Elem())
+	case reflect.Slice:
+		if v.IsNil() {
+			b.WriteString("null")
+			return nil
+		}
+		b.WriteByte('[')
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := encodeJSON(b, v.Index(i)); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+		return nil
+	case reflect.Struct:
+		if v.Type() == spanType {
+			return encodeLeaf(b, v)
+		}
+		b.WriteByte('{')
+		if err := encodeFields(b, v, false); err != nil {
+			return err
+		}
+		b.WriteByte('}')
+		return nil
+	}
+	return encodeLeaf(b, v)
+}
+
+// encodeNode encodes v with its kind.
+func encodeNode(b *bytes.Buffer, v reflect.Value) error {
+	kind := nodeKind(v.Type())
+	if _, ok := nodeKinds[kind]; !ok {
+		return fmt.Errorf("%s is not an AST node", v.Type())
+	}
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			b.WriteString("null")
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	fmt.Fprintf(b, `{"kind":%q`, kind)
+	if v.Kind() == reflect.Struct {
+		if err := encodeFields(b, v, true); err != nil {
+			return err
+		}
+	} else {
+		b.WriteString(`,"value":`)
+		if err := encodeJSON(b, v); err != nil {
+			return err
+		}
+	}
+	b.WriteByte('}')
+	return nil
+}
+
+func encodeFields(b *bytes.Buffer, v reflect.Value, needComma bool) error {
+	for i := 0; i < v.NumField(); i++ {
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		if needComma {
+			b.WriteByte(',')
+		}
+		needComma = true
+		fmt.Fprintf(b, "%q:", v.Type().Field(i).Name)
+		if err := encodeJSON(b, v.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeLeaf(b *bytes.Buffer, v reflect.Value) error {
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	b.Write(data)
+	return nil
+}
+
+// decodeJSON decodes data into v. If isTop, v is a TopStmt being decoded by its UnmarshalJSON,
+// whose fields are decoded one by one to avoid calling UnmarshalJSON again.
+func decodeJSON(data []byte, v reflect.Value, isTop bool) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		node, err := decodeNode(data)
+		if err != nil {
+			return err
+		}
+		if !node.Type().AssignableTo(v.Type()) {
+			return fmt.Errorf("a %s can not be used as %s", nodeKind(node.Type()), v.Type())
+		}
+		v.Set(node)
+		return nil
+	case reflect.Slice:
+		var elems []json.RawMessage
+		if err := json.Unmarshal(data, &elems); err != nil {
+			return err
+		}
+		slice := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+		for i, elem := range elems {
+			if err := decodeJSON(elem, slice.Index(i), false); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	case reflect.Struct:
+		if v.Type() == spanType || (v.Type() == reflect.TypeOf(TopStmt{}) && !isTop) {
+			return json.Unmarshal(data, v.Addr().Interface())
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+		return decodeFields(fields, v)
+	}
+	return json.Unmarshal(data, v.Addr().Interface())
+}
+
+func decodeFields(fields map[string]json.RawMessage, v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		data, ok := fields[field.Name]
+		if !ok || !field.IsExported() {
+			continue
+		}
+		if err := decodeJSON(data, v.Field(i), false); err != nil {
+			return fmt.Errorf("%s.%s: %w", v.Type().Name(), field.Name, err)
+		}
+	}
+	return nil
+}
+
+// decodeNode decodes an object tagged by its kind to a value of that kind.
+func decodeNode(data []byte) (reflect.Value, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return reflect.Value{}, err
+	}
+	var kind string
+	if err := json.Unmarshal(fields["kind"], &kind); err != nil {
+		return reflect.Value{}, fmt.Errorf("node without kind: %s", data)
+	}
+	if singleton, ok := singletons[kind]; ok {
+		return reflect.ValueOf(singleton), nil
+	}
+	t, ok := nodeKinds[kind]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("unknown kind %q", kind)
+	}
+
+	isPointer := t.Kind() == reflect.Pointer
+	if isPointer {
+		t = t.Elem()
+	}
+	ret := reflect.New(t).Elem()
+	var err error
+	if t.Kind() == reflect.Struct {
+		err = decodeFields(fields, ret)
+	} else {
+		err = decodeJSON(fields["value"], ret, false)
+	}
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	if isPointer {
+		return ret.Addr(), nil
+	}
+	return ret, nil
+}
@@ -0,0 +1,92 @@
+package litexparser
+
+import "reflect"
+
+// A node is a *TopStmt, a Stmt, an Fc, an FcType, an FcDecl or an FcVarTypeValue. Walk visits
+// every node of a tree in depth-first order: a statement before its facts and proofs, a function
+// call before its name and parameters. Structs which only group fields, like TypeConceptPair, and
+// names, like TypeVarStr, are not visited themselves.
+
+// A Visitor's Visit method is called for each node encountered by Walk. If the result w is not
+// nil, Walk visits each child of node with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node any) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order, starting with v.Visit(node).
+func Walk(v Visitor, node any) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	walkChildren(v, reflect.ValueOf(node))
+	v.Visit(nil)
+}
+
+type inspector func(any) bool
+
+func (f inspector) Visit(node any) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order, calling f(node) for each node. If f returns
+// true, Inspect visits the children of node, followed by a call of f(nil).
+func Inspect(node any, f func(any) bool) {
+	Walk(inspector(f), node)
+}
+
+func walkChildren(v Visitor, node reflect.Value) {
+	if node.Kind() == reflect.Pointer {
+		if node.IsNil() {
+			return
+		}
+		node = node.Elem()
+	}
+
+	switch node.Kind() {
+	case reflect.Struct:
+		for i := 0; i < node.NumField(); i++ {
+			if node.Type().Field(i).IsExported() {
+				walkValue(v, node.Field(i))
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < node.Len(); i++ {
+			walkValue(v, node.Index(i))
+		}
+	}
+}
+
+// walkValue walks the nodes in a field of a node.
+func walkValue(v Visitor, value reflect.Value) {
+	if isNodeType(value.Type()) {
+		Walk(v, value.Interface())
+		return
+	}
+	if value.CanAddr() && isNodeType(reflect.PointerTo(value.Type())) {
+		Walk(v, value.Addr().Interface())
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Interface:
+		if !value.IsNil() {
+			Walk(v, value.Elem().Interface())
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			walkValue(v, value.Index(i))
+		}
+	case reflect.Struct:
+		if value.Type() != spanType {
+			walkChildren(v, value)
+		}
+	}
+}
+
+func isNodeType(t reflect.Type) bool {
+	registered, ok := nodeKinds[nodeKind(t)]
+	return ok && registered == t
+}
@@ -29,6 +29,15 @@ func (b *TokenBlock) stringWithIndent(indentLevel int) string {
 	return result
 }
 
+// span returns the span from the first token of the header to the last token of the block.
+func (b *TokenBlock) span() Span {
+	ret := b.Header.span()
+	for i := range b.Body {
+		ret = ret.join(b.Body[i].span())
+	}
+	return ret
+}
+
 type topLevelStmtSlice struct {
 	body []strBlock
 }
@@ -37,10 +46,12 @@ type topLevelStmtSlice struct {
 type strBlock struct {
 	header string
 	body   []strBlock
+	doc    string // the doc comment right before the header
+	line   int    // line of the header, starting from 1
+	column int    // column of the first character of the header, starting from 1
+	end    int    // index of the line after the last line of the block, starting from 0
 }
 
-const parseIndent = 4
-
 // String 方法实现 fmt.Stringer 接口
 func (b *strBlock) String() string {
 	return b.stringWithIndent(0)
@@ -59,153 +70,232 @@ func (b *strBlock) stringWithIndent(indentLevel int) string {
 	return result
 }
 
-// ParseFile 读取文件并解析为 StmtBlock 结构
-func ParseFile(filePath string) (*topLevelStmtSlice, error) {
+// splitFile 读取文件并解析为 StmtBlock 结构
+func splitFile(filePath string) (*topLevelStmtSlice, error) {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("无法读取文件: %v", err)
 	}
 
-	return getTopLevelStmtSlice(string(content))
+	return splitBlocks(string(content), filePath, IndentPolicy{})
 }
 
 func getTopLevelStmtSlice(content string) (*topLevelStmtSlice, error) {
-	lines := strings.Split((content), "\n")
-	blocks, _, err := parseStrBlocks(lines, 0, 0)
-	if err != nil {
-		return nil, err
-	}
+	return splitBlocks(content, "", IndentPolicy{})
+}
 
-	return &topLevelStmtSlice{*blocks}, err
+// IndentPolicy tells how the bodies of blocks are indented. Each line is indented by a whole
+// number of units, and the body of a block header ending with : by one unit more than the header.
+type IndentPolicy struct {
+	Unit string // spaces or tabs indenting one level; empty to take the indentation of the first indented line of each file
 }
 
-func parseStrBlocks(lines []string, currentIndent int, startIndex int) (*[]strBlock, int, error) {
-	blocks := []strBlock{}
-	i := startIndex
+func (p IndentPolicy) validate() error {
+	if p.Unit != "" && strings.Trim(p.Unit, " ") != "" && strings.Trim(p.Unit, "\t") != "" {
+		return fmt.Errorf("invalid indentation unit %q: it must be made of spaces or of tabs", p.Unit)
+	}
+	return nil
+}
 
-	for i < len(lines) {
-		line := lines[i]
-		trimLine := strings.TrimSpace(line)
+// splitBlocks splits content read from file into top-level blocks. Every line which is not blank
+// or a comment belongs to a block: a line indented differently from what the blocks around it
+// expect is an error, positioned at its indentation. The error is ParseErrors, returned with the
+// blocks around the lines which do not fit, as parseTopLevelBlocks tells.
+func splitBlocks(content string, file string, policy IndentPolicy) (*topLevelStmtSlice, error) {
+	if err := policy.validate(); err != nil {
+		return nil, err
+	}
+	layout := blockLayout{lines: strings.Split(content, "\n"), file: file, unit: policy.Unit, unitLine: -1}
+	blocks, errs := layout.parseTopLevelBlocks(0)
+	if len(errs) > 0 {
+		return &topLevelStmtSlice{blocks}, errs
+	}
+	return &topLevelStmtSlice{blocks}, nil
+}
 
-		// 跳过空行
-		if trimLine == "" {
-			i++
-			continue
-		}
+// blockLayout splits lines of source code into blocks by their indentation.
+type blockLayout struct {
+	lines []string
+	file  string
+	unit  string // the indentation unit, empty until the first indented line if it is detected
+	// index of the line which sets the detected indentation unit, -1 if the unit is given or no
+	// line is indented yet
+	unitLine int
+}
 
-		// 跳过单行注释
-		if strings.HasPrefix(trimLine, "//") {
-			i++
-			continue
+// parseTopLevelBlocks parses the top-level blocks from the line with index i. The top-level block
+// with a layout error is dropped: the error is recorded, the lines up to the next line which is
+// not indented are skipped, and parsing goes on from there. At least the line the dropped block
+// starts on is skipped, so that a line which fails by itself is not parsed again.
+func (l *blockLayout) parseTopLevelBlocks(i int) ([]strBlock, ParseErrors) {
+	ret := []strBlock{}
+	errs := ParseErrors{}
+	for {
+		blocks, next, err := l.parseStrBlocks(0, i)
+		ret = append(ret, blocks...)
+		if err == nil {
+			return ret, errs
 		}
-
-		// 跳过多行注释
-		if strings.HasPrefix(trimLine, "/*") {
-			// 找到 */，可能跨越多行
-			j := i + 1
-			for j < len(lines) {
-				if strings.Contains(lines[j], "*/") {
-					if !strings.HasSuffix(strings.TrimSpace(lines[j]), "*/") {
-						return nil, 0, fmt.Errorf("invalid line: a line with */ should end with */:\n%s", lines[j])
-					}
-					break
-				}
-				j++
+		errs.add(err)
+		for i = max(next, i+1); i < len(l.lines); i++ {
+			if line := l.lines[i]; strings.TrimSpace(line) != "" && line[0] != ' ' && line[0] != '\t' {
+				break
 			}
-			i = j + 1
-			continue
 		}
+	}
+}
 
-		indent := len(line) - len(strings.TrimLeft(line, " "))
-
-		// 如果当前行的缩进小于当前块的缩进，返回
-		if indent < currentIndent {
-			return &blocks, i, nil
+// parseStrBlocks parses the blocks at the given indentation level, starting from the line with
+// index i. It returns them with the index of the first line after them, or, on a layout error,
+// the blocks before the failing one with the index of the line where the error is found.
+func (l *blockLayout) parseStrBlocks(level int, i int) ([]strBlock, int, error) {
+	blocks := []strBlock{}
+	for {
+		start := i
+		var doc string
+		var err error
+		if i, doc, err = l.skipComments(i); err != nil || i >= len(l.lines) {
+			return blocks, i, err
+		}
+		cur, width, err := l.indentLevel(i)
+		if err != nil {
+			return blocks, i, err
+		}
+		if cur < level {
+			// the comments before the line may be the doc comment of an outer block
+			return blocks, start, nil
+		}
+		if cur > level {
+			return blocks, i, l.errorf(i, width, "unexpected indentation: only the body of a line ending with ':' is indented")
 		}
 
-		// 如果当前行的缩进等于当前块的缩进，创建一个新的块
-		if indent == currentIndent {
-			block := strBlock{
-				header: strings.TrimSpace(line),
-				body:   nil,
+		header := strings.TrimSpace(l.lines[i])
+		block := strBlock{header: header, doc: doc, line: i + 1, column: width + 1}
+		i++
+		if opensBlock(header) {
+			next, _, err := l.skipComments(i)
+			if err != nil {
+				return blocks, next, err
 			}
+			if next >= len(l.lines) {
+				return blocks, next, l.errorf(block.line-1, width, "expected an indented block after '%s'", header)
+			}
+			nextLevel, nextWidth, err := l.indentLevel(next)
+			if err != nil {
+				return blocks, next, err
+			}
+			if nextLevel != level+1 {
+				return blocks, next, l.errorf(next, nextWidth, "expected a block indented by one level more than '%s' on line %d", header, block.line)
+			}
+			if block.body, i, err = l.parseStrBlocks(level+1, i); err != nil {
+				return blocks, i, err
+			}
+		}
+		block.end = i
+		blocks = append(blocks, block)
+	}
+}
 
-			// 如果 trimLine 以 : 结尾，检查下一行的缩进
-			if strings.HasSuffix(trimLine, ":") {
-				i++
-				// 找到下一个非空、非注释的行
-				nextLineIndex := i
-				for nextLineIndex < len(lines) {
-					nextLine := lines[nextLineIndex]
-					nextTrimLine := strings.TrimSpace(nextLine)
-
-					// 跳过空行和注释
-					if nextTrimLine == "" || strings.HasPrefix(nextTrimLine, "//") {
-						nextLineIndex++
-						continue
-					}
-
-					// 处理多行注释 /* ... */
-					if strings.HasPrefix(nextTrimLine, "/*") {
-						// 找到注释的结束位置 */
-						for nextLineIndex < len(lines) {
-							if strings.Contains(lines[nextLineIndex], "*/") {
-								if !strings.HasSuffix(strings.TrimSpace(lines[nextLineIndex]), "*/") {
-									return nil, 0, fmt.Errorf("invalid line: a line with */ should end with */:\n%s", lines[nextLineIndex])
-								}
-								nextLineIndex++
-								break
-							}
-							nextLineIndex++
-						}
-						continue
-					}
-
-					// 检查下一行的缩进是否等于 currentIndent + parseIndent
-					nextIndent := len(nextLine) - len(strings.TrimLeft(nextLine, " "))
-					if nextIndent != currentIndent+parseIndent {
-						return nil, i, fmt.Errorf("错误：'%s' 后的行缩进不正确，期望缩进 %d，实际缩进 %d", trimLine, currentIndent+parseIndent, nextIndent)
-					}
-
-					// 递归解析子块
-					subBlocks, nextIndex, err := parseStrBlocks(lines, currentIndent+parseIndent, nextLineIndex)
-					if err != nil {
-						return nil, i, err
-					}
-					block.body = *subBlocks
-					i = nextIndex
-					break
-				}
+// opensBlock reports whether header ends with :, before the comment it may end with.
+func opensBlock(header string) bool {
+	code, _, _ := strings.Cut(header, "//")
+	return strings.HasSuffix(strings.TrimSpace(code), ":")
+}
 
-				// 如果没有找到有效的下一行，报错
-				if nextLineIndex >= len(lines) {
-					return nil, i, fmt.Errorf("错误：'%s' 后缺少缩进的子块", trimLine)
+// skipComments returns the index of the first line from i which is not blank or a comment, and
+// the doc comment right before it.
+func (l *blockLayout) skipComments(i int) (int, string, error) {
+	doc := []string{}
+	for i < len(l.lines) {
+		trimLine := strings.TrimSpace(l.lines[i])
+		switch {
+		case trimLine == "":
+			doc = doc[:0]
+			i++
+		case strings.HasPrefix(trimLine, "//"):
+			if text, ok := docLine(trimLine); ok {
+				doc = append(doc, text)
+			} else {
+				doc = doc[:0]
+			}
+			i++
+		case strings.HasPrefix(trimLine, "/*"):
+			// 找到 */，可能跨越多行
+			start := i
+			comment := []string{trimLine[len("/*"):]}
+			for !strings.Contains(comment[len(comment)-1], "*/") {
+				if i++; i >= len(l.lines) {
+					return i, "", l.errorf(start, 0, "comment block is not closed by */")
 				}
+				comment = append(comment, l.lines[i])
+			}
+			if !strings.HasSuffix(strings.TrimSpace(comment[len(comment)-1]), "*/") {
+				return i, "", l.errorf(i, 0, "invalid line: a line with */ should end with */")
+			}
+			if text, ok := docBlock(comment); ok {
+				doc = append(doc[:0], text...)
 			} else {
-				// 否则，直接跳过该行
-				i++
+				doc = doc[:0]
 			}
-
-			blocks = append(blocks, block)
-		} else {
-			// 如果缩进不符合预期，跳过该行
 			i++
+		default:
+			return i, strings.Join(doc, "\n"), nil
 		}
 	}
+	return i, "", nil
+}
+
+// indentLevel returns the indentation level of the line with index i and the width of its
+// indentation. The first indented line sets the unit if it is not given.
+func (l *blockLayout) indentLevel(i int) (int, int, error) {
+	line := l.lines[i]
+	indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	if indent == "" {
+		return 0, 0, nil
+	}
+	if strings.Contains(indent, " ") && strings.Contains(indent, "\t") {
+		return 0, 0, l.errorf(i, len(indent), "indentation mixes tabs and spaces")
+	}
+	if l.unit == "" {
+		l.unit = indent
+		l.unitLine = i
+	}
+	if indent[0] != l.unit[0] || len(indent)%len(l.unit) != 0 {
+		return 0, 0, l.errorf(i, len(indent), "indentation of %s is not a multiple of the indentation unit, %s", describeIndent(indent), describeIndent(l.unit))
+	}
+	return len(indent) / len(l.unit), len(indent), nil
+}
 
-	return &blocks, i, nil
+func describeIndent(indent string) string {
+	name := "space"
+	if indent[0] == '\t' {
+		name = "tab"
+	}
+	if len(indent) != 1 {
+		name += "s"
+	}
+	return fmt.Sprintf("%d %s", len(indent), name)
+}
+
+// errorf returns an indentation error at the first width columns of the line with index i.
+func (l *blockLayout) errorf(i int, width int, format string, args ...any) error {
+	return &Diagnostic{
+		Code:     DiagIndentationError,
+		Severity: SeverityError,
+		Message:  fmt.Sprintf(format, args...),
+		Span:     Span{l.file, Position{i + 1, 1}, Position{i + 1, max(width, 1) + 1}},
+	}
 }
 
 func ParseSourceCodeGetTokenBlock(code string) (*[]TokenBlock, error) {
-	lines := strings.Split(code, "\n")
-	strBlocks, _, err := parseStrBlocks(lines, 0, 0)
+	slice, err := getTopLevelStmtSlice(code)
 	if err != nil {
 		return nil, err
 	}
 
-	tokenBlocks := []TokenBlock{}
-	for _, strBlock := range *strBlocks {
-		block, err := TokenizeStmtBlock(&strBlock)
+	tokenBlocks := make([]TokenBlock, 0, len(slice.body))
+	for i := range slice.body {
+		block, err := TokenizeStmtBlock(&slice.body[i])
 		if err != nil {
 			return nil, err
 		}
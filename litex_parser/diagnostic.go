@@ -0,0 +1,228 @@
+package litexparser
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+type Severity uint8
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityInfo
+)
+
+var severityNames = map[Severity]string{
+	SeverityError:   "error",
+	SeverityWarning: "warning",
+	SeverityInfo:    "info",
+}
+
+func (s Severity) String() string {
+	if name, ok := severityNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("Severity(%d)", uint8(s))
+}
+
+func (s Severity) MarshalText() ([]byte, error) {
+	if name, ok := severityNames[s]; ok {
+		return []byte(name), nil
+	}
+	return nil, fmt.Errorf("unknown severity %d", uint8(s))
+}
+
+func (s *Severity) UnmarshalText(text []byte) error {
+	for severity, name := range severityNames {
+		if name == string(text) {
+			*s = severity
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", text)
+}
+
+// DiagnosticCode identifies a kind of diagnostic. Codes never change meaning once released:
+// E for errors and W for warnings, 00xx for parsing and 01xx for execution.
+type DiagnosticCode string
+
+const (
+	DiagError            DiagnosticCode = "E0000" // an error without a more specific code
+	DiagSyntaxError      DiagnosticCode = "E0001"
+	DiagUnexpectedEnd    DiagnosticCode = "E0002"
+	DiagTrailingTokens   DiagnosticCode = "E0003"
+	DiagNotationError    DiagnosticCode = "E0004"
+	DiagIndentationError DiagnosticCode = "E0005"
+	DiagDisabledFeature  DiagnosticCode = "E0006"
+	DiagDroppedComment   DiagnosticCode = "E0007" // formatting would drop a comment
+	DiagExecError        DiagnosticCode = "E0100"
+
+	DiagDuplicateFact DiagnosticCode = "W0100"
+	DiagImpliedFact   DiagnosticCode = "W0101"
+	DiagSubsumingFact DiagnosticCode = "W0102"
+)
+
+type RelatedSpan struct {
+	Span Span   `json:"span"`
+	Note string `json:"note"`
+}
+
+// SuggestedFix replaces the source code at Span by Replacement.
+type SuggestedFix struct {
+	Message     string `json:"message"`
+	Span        Span   `json:"span"`
+	Replacement string `json:"replacement"`
+}
+
+// Diagnostic is an error, warning or note about source code. It encodes to JSON with encoding/json
+// and renders as text with Render.
+type Diagnostic struct {
+	Code     DiagnosticCode `json:"code"`
+	Severity Severity       `json:"severity"`
+	Message  string         `json:"message"`
+	Span     Span           `json:"span"`
+	Related  []RelatedSpan  `json:"related,omitempty"`
+	Fix      *SuggestedFix  `json:"fix,omitempty"`
+}
+
+func (d *Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s", d.Span, d.Message)
+}
+
+// Render formats d like rustc: a header line, then the source line of each span with the span
+// underlined. sources maps file names to their content, with "" for source code not read from a
+// file. Spans whose source is not given are printed without the source line.
+func (d *Diagnostic) Render(sources map[string]string) string {
+	gutter := len(fmt.Sprint(d.Span.Start.Line))
+	for _, related := range d.Related {
+		gutter = max(gutter, len(fmt.Sprint(related.Span.Start.Line)))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s[%s]: %s\n", d.Severity, d.Code, d.Message)
+	renderSnippet(&b, d.Span, sources, gutter)
+	for _, related := range d.Related {
+		fmt.Fprintf(&b, "note: %s\n", related.Note)
+		renderSnippet(&b, related.Span, sources, gutter)
+	}
+	if d.Fix != nil {
+		if d.Fix.Replacement == "" {
+			fmt.Fprintf(&b, "help: %s\n", d.Fix.Message)
+		} else {
+			fmt.Fprintf(&b, "help: %s: `%s`\n", d.Fix.Message, d.Fix.Replacement)
+		}
+	}
+	return b.String()
+}
+
+func renderSnippet(b *strings.Builder, span Span, sources map[string]string, gutter int) {
+	pad := strings.Repeat(" ", gutter)
+	fmt.Fprintf(b, "%s--> %s\n", pad, span)
+
+	source, ok := sources[span.File]
+	if !ok || !span.IsValid() {
+		return
+	}
+	lines := strings.Split(source, "\n")
+	if span.Start.Line > len(lines) {
+		return
+	}
+	line := lines[span.Start.Line-1]
+
+	width := 1
+	if span.End.Line == span.Start.Line && span.End.Column > span.Start.Column {
+		width = span.End.Column - span.Start.Column
+	} else if span.End.Line > span.Start.Line {
+		// only the first line of a span over several lines is shown
+		width = max(1, utf8.RuneCountInString(line)-span.Start.Column+1)
+	}
+
+	fmt.Fprintf(b, "%s |\n", pad)
+	fmt.Fprintf(b, "%*d | %s\n", gutter, span.Start.Line, line)
+	fmt.Fprintf(b, "%s | %s%s\n", pad, caretIndent(line, span.Start.Column), strings.Repeat("^", width))
+}
+
+// caretIndent returns the blanks before column of line, keeping its tabs so the caret lines up.
+func caretIndent(line string, column int) string {
+	var b strings.Builder
+	for _, r := range line {
+		if column--; column <= 0 {
+			break
+		}
+		if r == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+	b.WriteString(strings.Repeat(" ", max(column-1, 0)))
+	return b.String()
+}
+
+// Diagnose converts an error returned by the parser or the executor to diagnostics: one for each
+// error of ParseErrors. Errors of other packages become diagnostics by implementing Diagnostic().
+func Diagnose(err error) []Diagnostic {
+	switch e := err.(type) {
+	case nil:
+		return nil
+	case ParseErrors:
+		ret := []Diagnostic{}
+		for _, cur := range e {
+			ret = append(ret, Diagnose(cur)...)
+		}
+		return ret
+	case *Diagnostic:
+		return []Diagnostic{*e}
+	case interface{ Diagnostic() Diagnostic }:
+		return []Diagnostic{e.Diagnostic()}
+	case *parserErr, *parseStmtErr:
+		return []Diagnostic{diagnoseParseErr(err)}
+	}
+	return []Diagnostic{{Code: DiagError, Severity: SeverityError, Message: err.Error()}}
+}
+
+// diagnoseParseErr reports the innermost error of nested parser errors, which is the most precise,
+// and notes the statement it is in.
+func diagnoseParseErr(err error) Diagnostic {
+	var stmtHeader *Parser // header of the outermost statement
+	var cur *Parser        // parser of the innermost error
+unwrap:
+	for {
+		switch e := err.(type) {
+		case *parserErr:
+			cur = e.parser
+			err = e.previous
+		case *parseStmtErr:
+			header := e.stmt.Header
+			if stmtHeader == nil {
+				stmtHeader = &header
+			}
+			cur = &header
+			err = e.previous
+		default:
+			break unwrap
+		}
+	}
+
+	ret := Diagnostic{Code: DiagSyntaxError, Severity: SeverityError, Message: err.Error()}
+	switch e := err.(type) {
+	case *unexpectedEndErr:
+		ret.Code = DiagUnexpectedEnd
+		ret.Message = "unexpected end of statement"
+	case *trailingTokensErr:
+		ret.Code = DiagTrailingTokens
+		ret.Fix = &SuggestedFix{"remove the tokens", cur.spanBetween(cur.getIndex(), len(cur.slice)), ""}
+	case *Diagnostic:
+		ret = *e
+	}
+
+	if !ret.Span.IsValid() && cur != nil {
+		ret.Span = cur.currentSpan()
+	}
+	if stmtHeader != nil && stmtHeader.span() != ret.Span {
+		ret.Related = append(ret.Related, RelatedSpan{stmtHeader.span(), "in this statement"})
+	}
+	return ret
+}
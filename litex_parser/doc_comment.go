@@ -0,0 +1,163 @@
+package litexparser
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// A doc comment is a run of /// line comments, or a /** */ block comment, right before a prop,
+// fn, type, concept, axiom or thm declaration:
+//
+//	/// the sum is commutative
+//	/// for every pair of reals
+//	axiom prop add_commutes(x R, y R):
+//	    x + y = y + x
+//
+// Its text, without the comment markers, is the Doc of the declaration. A blank line or another
+// comment between the doc comment and the declaration detaches it. Doc comments before other
+// statements are ordinary comments.
+
+// docLine returns the text of a /// line comment. A comment starting with //// is not a doc
+// comment.
+func docLine(line string) (string, bool) {
+	if !strings.HasPrefix(line, "///") || strings.HasPrefix(line, "////") {
+		return "", false
+	}
+	return strings.TrimPrefix(line[len("///"):], " "), true
+}
+
+// docBlock returns the lines of a /** */ block comment given by its lines after the leading /*.
+// The * which starts each line of the block, if any, is not part of the text.
+func docBlock(comment []string) ([]string, bool) {
+	if !strings.HasPrefix(comment[0], "*") || strings.HasPrefix(comment[0], "*/") {
+		return nil, false
+	}
+	comment[0] = comment[0][len("*"):]
+	last := len(comment) - 1
+	comment[last] = strings.TrimSuffix(strings.TrimSpace(comment[last]), "*/")
+
+	ret := []string{}
+	for _, line := range comment {
+		line = strings.TrimSpace(line)
+		if line != "*" {
+			line = strings.TrimPrefix(line, "* ")
+		} else {
+			line = ""
+		}
+		ret = append(ret, strings.TrimRight(line, " \t"))
+	}
+	// the lines of /** and */ are usually empty
+	for len(ret) > 0 && ret[0] == "" {
+		ret = ret[1:]
+	}
+	for len(ret) > 0 && ret[len(ret)-1] == "" {
+		ret = ret[:len(ret)-1]
+	}
+	return ret, true
+}
+
+// scanComments returns the span of the first line of each doc comment of code which has text, and
+// the span of the first comment which is not a doc comment, nil if there is none.
+func scanComments(code string) ([]Span, *Span) {
+	docs := []Span{}
+	lines := strings.Split(code, "\n")
+	inDoc := false // whether the previous line is a /// line of a doc comment already counted
+	for i := 0; i < len(lines); i++ {
+		trimLine := strings.TrimSpace(lines[i])
+		start := Position{i + 1, len(lines[i]) - len(strings.TrimLeft(lines[i], " \t")) + 1}
+		if text, ok := docLine(trimLine); ok {
+			if !inDoc && text != "" {
+				docs = append(docs, Span{Start: start, End: start})
+				inDoc = true
+			}
+			continue
+		}
+		inDoc = false
+
+		if strings.HasPrefix(trimLine, "/*") {
+			comment := []string{trimLine[len("/*"):]}
+			for !strings.Contains(comment[len(comment)-1], "*/") && i+1 < len(lines) {
+				i++
+				comment = append(comment, lines[i])
+			}
+			text, ok := docBlock(comment)
+			if !ok {
+				return docs, &Span{Start: start, End: start}
+			}
+			if len(text) > 0 {
+				docs = append(docs, Span{Start: start, End: start})
+			}
+			continue
+		}
+
+		// a comment after code
+		if j := commentStart(lines[i]); j >= 0 {
+			start.Column = utf8.RuneCountInString(lines[i][:j]) + 1
+			return docs, &Span{Start: start, End: start}
+		}
+	}
+	return docs, nil
+}
+
+// commentStart returns the byte index of the first // or /* of line, -1 if there is none.
+func commentStart(line string) int {
+	for i := 0; i+1 < len(line); i++ {
+		if line[i] == '/' && (line[i+1] == '/' || line[i+1] == '*') {
+			return i
+		}
+	}
+	return -1
+}
+
+// docComment prints doc as /// line comments, each ending with a newline.
+func docComment(doc string) string {
+	if doc == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, line := range strings.Split(doc, "\n") {
+		b.WriteString(strings.TrimRight("/// "+line, " ") + "\n")
+	}
+	return b.String()
+}
+
+// doc returns the doc comment before the header of b.
+func (b *TokenBlock) doc() string {
+	if len(b.Header.slice) == 0 {
+		return ""
+	}
+	return b.Header.slice[0].doc
+}
+
+func (s *DefConceptStmt) setDoc(doc string) { s.Doc = doc }
+func (s *DefTypeStmt) setDoc(doc string)    { s.Doc = doc }
+func (s *DefPropStmt) setDoc(doc string)    { s.Doc = doc }
+func (s *DefFnStmt) setDoc(doc string)      { s.Doc = doc }
+func (s *AxiomStmt) setDoc(doc string)      { s.Doc = doc }
+func (s *ThmStmt) setDoc(doc string)        { s.Doc = doc }
+
+// setStmtDoc records doc on stmt if stmt is a declaration which has a doc comment.
+func setStmtDoc(stmt Stmt, doc string) {
+	if s, ok := stmt.(interface{ setDoc(string) }); ok && doc != "" {
+		s.setDoc(doc)
+	}
+}
+
+// stmtDoc returns the doc comment of stmt, or "" if it has none.
+func stmtDoc(stmt Stmt) string {
+	switch s := stmt.(type) {
+	case *DefConceptStmt:
+		return s.Doc
+	case *DefTypeStmt:
+		return s.Doc
+	case *DefPropStmt:
+		return s.Doc
+	case *DefFnStmt:
+		return s.Doc
+	case *AxiomStmt:
+		return s.Doc
+	case *ThmStmt:
+		return s.Doc
+	}
+	return ""
+}
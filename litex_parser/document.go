@@ -0,0 +1,340 @@
+package litexparser
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Document is source code open in an editor. It keeps the blocks and statements of its last parse,
+// so that an edit reparses only the top-level blocks it touches, and reports which statements
+// change. The statements of a Document are those Parse returns for its text.
+type Document struct {
+	opts     ParseOptions // opts.Notations is never changed
+	lines    []string
+	unit     string // the indentation unit of the last parse
+	unitLine int    // index of the line which sets the detected indentation unit, -1 if none does
+	blocks   []documentBlock
+	err      error // the errors splitting the lines into blocks, if any
+}
+
+type documentBlock struct {
+	str  strBlock
+	stmt *TopStmt // nil if the block does not parse
+	errs ParseErrors
+}
+
+// TextEdit replaces the text from Start up to, but not including, End with Text.
+type TextEdit struct {
+	Start Position
+	End   Position
+	Text  string
+}
+
+// DocumentChange tells which top-level statements an edit changes. The statements with an index
+// before First are unchanged. From First on, the statements whose index is in Changed are parsed
+// again; the others are the statements from before the edit, with the index and the lines they
+// move to. Statements are numbered like TopStmt.Index, counting those which do not parse.
+type DocumentChange struct {
+	First   int
+	Changed []int
+}
+
+// NewDocument parses code like Parse, except that the notations which code declares are not added
+// to opts.Notations. opts.Trace is called for each statement parsed by NewDocument and Edit.
+func NewDocument(code string, opts ParseOptions) *Document {
+	if opts.Notations == nil {
+		opts.Notations = NewNotationTable()
+	}
+	d := &Document{opts: opts, lines: strings.Split(code, "\n")}
+	d.parseAll()
+	return d
+}
+
+func (d *Document) Text() string {
+	return strings.Join(d.lines, "\n")
+}
+
+// Statements returns the statements which parse, in order.
+func (d *Document) Statements() []TopStmt {
+	ret := []TopStmt{}
+	for _, block := range d.blocks {
+		if block.stmt != nil {
+			ret = append(ret, *block.stmt)
+		}
+	}
+	return ret
+}
+
+// Err returns the errors of the last parse like ParseSourceCode, or nil.
+func (d *Document) Err() error {
+	errs := ParseErrors{}
+	if d.err != nil {
+		errs.add(d.err)
+	}
+	for _, block := range d.blocks {
+		errs = append(errs, block.errs...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Edit applies edit to the text of the document and parses the top-level blocks it changes. The
+// error is about edit itself; errors of the new text are returned by Err.
+func (d *Document) Edit(edit TextEdit) (*DocumentChange, error) {
+	lines, err := applyTextEdit(d.lines, edit)
+	if err != nil {
+		return nil, err
+	}
+	delta := len(lines) - len(d.lines)
+	d.lines = lines
+
+	// a layout error may be fixed by any edit
+	if d.err != nil || len(d.blocks) == 0 {
+		return d.parseAll(), nil
+	}
+
+	// the edited lines may become the body of the block before them or the doc comment of the
+	// block after them, so both are parsed again
+	first := max(d.blockAt(edit.Start.Line-1)-1, 0)
+	last := min(d.blockAt(edit.End.Line-1)+1, len(d.blocks)-1)
+	from := d.blockFrom(first)
+	to := len(d.lines)
+	if last+1 < len(d.blocks) {
+		to = d.blockFrom(last+1) + delta
+	}
+
+	// the unit is detected again if the first indented line may be edited
+	detect := d.opts.Indent.Unit == "" && (d.unitLine < 0 || from <= d.unitLine)
+	layout := blockLayout{lines: d.lines[:to], file: d.opts.File, unit: d.unit, unitLine: -1}
+	if detect {
+		layout.unit = ""
+	}
+	strBlocks, _, err := layout.parseStrBlocks(0, from)
+	if err != nil || to < len(d.lines) && (len(strBlocks) == 0 || strBlocks[len(strBlocks)-1].end != to) {
+		// the blocks after the edited lines are not split as before, e.g. an edit opens a comment
+		return d.parseAll(), nil
+	}
+	if detect {
+		switch {
+		case layout.unit == "":
+			// no edited line is indented, so the first indented line is after them
+			if d.unitLine >= 0 {
+				d.unitLine += delta
+			}
+		case d.unitLine >= 0 && layout.unit != d.unit:
+			// the lines after the edited ones are indented by another unit
+			return d.parseAll(), nil
+		default:
+			d.unit, d.unitLine = layout.unit, layout.unitLine
+		}
+	}
+
+	// a notation applies to the statements after it, so they are parsed again if one changes
+	parseRest := false
+	for _, block := range d.blocks[first : last+1] {
+		parseRest = parseRest || declaresNotation(&block.str)
+	}
+	for i := range strBlocks {
+		parseRest = parseRest || declaresNotation(&strBlocks[i])
+	}
+
+	notations := d.notationsBefore(first)
+	blocks := append([]documentBlock{}, d.blocks[:first]...)
+	change := &DocumentChange{First: first, Changed: []int{}}
+	for _, str := range strBlocks {
+		stmt, errs := d.opts.parseTopLevelBlock(&str, len(blocks), notations)
+		change.Changed = append(change.Changed, len(blocks))
+		blocks = append(blocks, documentBlock{str, stmt, errs})
+	}
+	for _, block := range d.blocks[last+1:] {
+		i := len(blocks)
+		block.str.moveLines(delta)
+		// the positions of parse errors are not moved, so a block with errors is parsed again
+		if parseRest || delta != 0 && len(block.errs) != 0 {
+			block.stmt, block.errs = d.opts.parseTopLevelBlock(&block.str, i, notations)
+			change.Changed = append(change.Changed, i)
+		} else if block.stmt != nil {
+			block.stmt = block.stmt.moved(delta, i)
+			declareNotationOf(block.stmt, notations)
+		}
+		blocks = append(blocks, block)
+	}
+	d.blocks = blocks
+	return change, nil
+}
+
+// parseAll parses the whole document again.
+func (d *Document) parseAll() *DocumentChange {
+	d.blocks, d.err = nil, nil
+	change := &DocumentChange{First: 0, Changed: []int{}}
+	if err := d.opts.Indent.validate(); err != nil {
+		d.err = err
+		return change
+	}
+
+	layout := blockLayout{lines: d.lines, file: d.opts.File, unit: d.opts.Indent.Unit, unitLine: -1}
+	strBlocks, errs := layout.parseTopLevelBlocks(0)
+	if len(errs) > 0 {
+		d.err = errs
+	}
+	d.unit, d.unitLine = layout.unit, layout.unitLine
+
+	notations := d.opts.Notations.clone()
+	for i, str := range strBlocks {
+		stmt, errs := d.opts.parseTopLevelBlock(&str, i, notations)
+		d.blocks = append(d.blocks, documentBlock{str, stmt, errs})
+		change.Changed = append(change.Changed, i)
+	}
+	return change
+}
+
+// blockFrom returns the index of the first line after the block before the block with index i.
+// The comments between the two blocks belong to the block with index i.
+func (d *Document) blockFrom(i int) int {
+	if i == 0 {
+		return 0
+	}
+	return d.blocks[i-1].str.end
+}
+
+// blockAt returns the index of the block whose lines, or the comments before it, include the line
+// with the given index.
+func (d *Document) blockAt(line int) int {
+	return sort.Search(len(d.blocks), func(i int) bool { return d.blockFrom(i) > line }) - 1
+}
+
+// notationsBefore returns the notations which apply to the block with index i.
+func (d *Document) notationsBefore(i int) *NotationTable {
+	ret := d.opts.Notations.clone()
+	for _, block := range d.blocks[:i] {
+		if block.stmt != nil {
+			declareNotationOf(block.stmt, ret)
+		}
+	}
+	return ret
+}
+
+// declareNotationOf declares the notation of stmt, which is declared without error when stmt is
+// parsed with the same notations before.
+func declareNotationOf(stmt *TopStmt, notations *NotationTable) {
+	if decl, ok := stmt.Stmt.(*DefNotationStmt); ok {
+		_ = notations.Declare(decl, stmt.IsPub)
+	}
+}
+
+func declaresNotation(b *strBlock) bool {
+	words := strings.Fields(b.header)
+	if len(words) > 0 && words[0] == Keywords["pub"] {
+		words = words[1:]
+	}
+	return len(words) > 0 && words[0] == Keywords["notation"]
+}
+
+// applyTextEdit returns lines with edit applied.
+func applyTextEdit(lines []string, edit TextEdit) ([]string, error) {
+	if positionBefore(edit.End, edit.Start) {
+		return nil, fmt.Errorf("the edit ends at %s, before it starts at %s", edit.End, edit.Start)
+	}
+	start, err := lineOffset(lines, edit.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := lineOffset(lines, edit.End)
+	if err != nil {
+		return nil, err
+	}
+
+	text := lines[edit.Start.Line-1][:start] + edit.Text + lines[edit.End.Line-1][end:]
+	ret := append([]string{}, lines[:edit.Start.Line-1]...)
+	ret = append(ret, strings.Split(text, "\n")...)
+	return append(ret, lines[edit.End.Line:]...), nil
+}
+
+// lineOffset returns the byte offset of pos in its line.
+func lineOffset(lines []string, pos Position) (int, error) {
+	if pos.Line < 1 || pos.Line > len(lines) {
+		return 0, fmt.Errorf("line %d is out of the document of %d lines", pos.Line, len(lines))
+	}
+	line := lines[pos.Line-1]
+	if pos.Column < 1 || pos.Column > utf8.RuneCountInString(line)+1 {
+		return 0, fmt.Errorf("column %d is out of line %d", pos.Column, pos.Line)
+	}
+	offset := 0
+	for column := 1; column < pos.Column; column++ {
+		_, size := utf8.DecodeRuneInString(line[offset:])
+		offset += size
+	}
+	return offset, nil
+}
+
+func (b *strBlock) moveLines(delta int) {
+	b.line += delta
+	b.end += delta
+	for i := range b.body {
+		b.body[i].moveLines(delta)
+	}
+}
+
+// moved returns a copy of stmt with index i, moved down by lines. The statement of stmt is copied
+// if it moves, so that statements returned before the edit keep their positions.
+func (stmt *TopStmt) moved(lines int, i int) *TopStmt {
+	ret := *stmt
+	ret.Index = i
+	if lines != 0 {
+		ret.Line += lines
+		ret.Stmt = movedNode(reflect.ValueOf(stmt.Stmt), lines).Interface().(Stmt)
+	}
+	return &ret
+}
+
+// movedNode returns a copy of v with every valid span moved down by lines. Like the JSON encoding,
+// it only follows exported fields, which hold the whole AST.
+func movedNode(v reflect.Value, lines int) reflect.Value {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		ret := reflect.New(v.Type()).Elem()
+		ret.Set(movedNode(v.Elem(), lines))
+		return ret
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		ret := reflect.New(v.Type().Elem())
+		ret.Elem().Set(movedNode(v.Elem(), lines))
+		return ret
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		ret := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			ret.Index(i).Set(movedNode(v.Index(i), lines))
+		}
+		return ret
+	case reflect.Struct:
+		if v.Type() == spanType {
+			span := v.Interface().(Span)
+			if span.IsValid() {
+				span.Start.Line += lines
+				span.End.Line += lines
+			}
+			return reflect.ValueOf(span)
+		}
+		ret := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).IsExported() {
+				ret.Field(i).Set(movedNode(v.Field(i), lines))
+			}
+		}
+		return ret
+	}
+	return v
+}
@@ -5,39 +5,91 @@ import (
 	"strconv"
 )
 
+// FcInfixOptPrecedence is how tightly an operator binds its operands: operators of higher
+// precedence are applied first.
+//
+//	precedence          operators                  associativity
+//	precOr              ||                         left
+//	precAnd             &&                         left
+//	precEquality        = == !=                    left
+//	precComparison      < > <= >=                  left
+//	precMembership      \in \has                   left
+//	precBitOr           |                          left
+//	precBitAnd          &                          left
+//	precAddition        + - ++ --                  left
+//	precMultiplication  * / %                      left
+//	precUnary           prefix - ! ~               -
+//	precExponentiation  ^ **                       right
+//	precIndex           @                          left
+//
+// So -a ^ 2 is -(a ^ 2), a ^ b ^ c is a ^ (b ^ c) and v@n + 1 is (v@n) + 1. The two sides of a
+// relation fact bind tighter than comparisons: a + 1 < b is a fact relating a + 1 and b.
 type FcInfixOptPrecedence int
 
 const (
-	precLowest         FcInfixOptPrecedence = iota
-	precAssignment                          // =
-	precOr                                  // or
-	precAnd                                 // and
-	precEquality                            // == !=
-	precComparison                          // < > <= >=
-	precAddition                            // + -
-	precMultiplication                      // / *
-	precUnary                               // - !
-	precExponentiation                      // ^
+	precLowest FcInfixOptPrecedence = iota
+	precOr
+	precAnd
+	precEquality
+	precComparison
+	precMembership
+	precBitOr
+	precBitAnd
+	precAddition
+	precMultiplication
+	precUnary
+	precExponentiation
+	precIndex
 )
 
 var precedenceMap = map[string]FcInfixOptPrecedence{
-	"+": precAddition,
-	"-": precAddition,
-	"*": precMultiplication,
-	"/": precMultiplication,
-	"^": precExponentiation,
+	"||":    precOr,
+	"&&":    precAnd,
+	"=":     precEquality,
+	"==":    precEquality,
+	"!=":    precEquality,
+	"<":     precComparison,
+	">":     precComparison,
+	"<=":    precComparison,
+	">=":    precComparison,
+	"\\in":  precMembership,
+	"\\has": precMembership,
+	"|":     precBitOr,
+	"&":     precBitAnd,
+	"+":     precAddition,
+	"-":     precAddition,
+	"++":    precAddition,
+	"--":    precAddition,
+	"*":     precMultiplication,
+	"/":     precMultiplication,
+	"%":     precMultiplication,
+	"^":     precExponentiation,
+	"**":    precExponentiation,
+	"@":     precIndex,
+}
+
+// infix operators not listed here are left associative
+var rightAssociative = map[string]bool{
+	"^":  true,
+	"**": true,
 }
 
 // All Unary operators have higher precedence than infix operators
 var unaryPrecedence = map[string]FcInfixOptPrecedence{
 	"-": precUnary,
+	"!": precUnary,
+	"~": precUnary,
 }
 
 func (parser *Parser) parseFcAtom() (Fc, error) {
 	if parser.is(BuiltinSyms["("]) {
 		return parser.parseBracedFcExpr()
 	}
+	if (parser.is(Keywords["fn"]) || parser.is(Keywords["prop"])) && parser.strAt(1) == BuiltinSyms["("] {
+		return parser.parseFcLambda()
+	}
 
+	start := parser.getIndex()
 	var curFc Fc
 	var err error
 
@@ -72,9 +124,7 @@ func (parser *Parser) parseFcAtom() (Fc, error) {
 		fcArr = append(fcArr, curFc)
 	}
 
-	ret := FcMemChain(fcArr)
-
-	return &ret, nil
+	return &FcMemChain{fcArr, parser.spanFrom(start)}, nil
 }
 
 func (parser *Parser) parseBracedFcExpr() (Fc, error) {
@@ -99,6 +149,7 @@ func (parser *Parser) parseFcStrAndFcFnRetVal() (Fc, error) {
 }
 
 func (parser *Parser) parseFcFnRetVal() (Fc, error) {
+	start := parser.getIndex()
 	optName, err := parser.parseFcStr()
 	if err != nil {
 		return nil, err
@@ -110,7 +161,7 @@ func (parser *Parser) parseFcFnRetVal() (Fc, error) {
 		return nil, err
 	}
 
-	return &FcFnRetValue{optName, *typeParamsVarParamsPairs}, nil
+	return &FcFnRetValue{optName, *typeParamsVarParamsPairs, parser.spanFrom(start)}, nil
 }
 
 func (parser *Parser) parseTypeParamsVarParamsPairs() (*[]TypeParamsAndParamsPair, error) {
@@ -142,19 +193,26 @@ func (parser *Parser) parseTypeParamsVarParamsPairs() (*[]TypeParamsAndParamsPai
 }
 
 func (parser *Parser) parseFcStr() (FcStr, error) {
-
+	start := parser.getIndex()
 	tok, err := parser.next()
 	if err != nil {
-		return "", err
+		return FcStr{}, err
 	}
-	return FcStr(tok), nil
+	return FcStr{tok, parser.spanFrom(start)}, nil
 }
 
 func (parser *Parser) ParseFc() (Fc, error) {
 	return parser.parseFcInfixExpr(precLowest)
 }
 
+// parseRelationOperand parses a side of a relation fact, which stops before relational operators
+// and the logical operators of lower precedence.
+func (parser *Parser) parseRelationOperand() (Fc, error) {
+	return parser.parseFcInfixExpr(precComparison)
+}
+
 func (parser *Parser) parseFcInfixExpr(currentPrec FcInfixOptPrecedence) (Fc, error) {
+	start := parser.getIndex()
 	left, err := parser.parseFcUnaryExpr()
 	if err != nil {
 		return nil, &parserErr{err, parser}
@@ -167,20 +225,36 @@ func (parser *Parser) parseFcInfixExpr(currentPrec FcInfixOptPrecedence) (Fc, er
 			return left, nil
 		}
 
+		curNotation := parser.currentNotation()
 		curPrec, isBinary := precedenceMap[curToken]
+		if curNotation != nil && !curNotation.decl.IsPrefix {
+			curPrec, isBinary = curNotation.precedence, true
+		}
 		if !isBinary || curPrec <= currentPrec {
 			break
 		}
 
+		optSpan := parser.currentSpan()
 		parser.skip() // 消耗运算符
-		right, err := parser.parseFcInfixExpr(curPrec)
+		rightPrec := curPrec
+		if rightAssociative[curToken] || (curNotation != nil && curNotation.decl.IsRightAssoc) {
+			// the right operand takes the following operators of the same precedence
+			rightPrec--
+		}
+		right, err := parser.parseFcInfixExpr(rightPrec)
 		if err != nil {
 			return nil, &parserErr{err, parser}
 		}
 
+		if curNotation != nil {
+			left = notationApplication(curNotation, optSpan, []Fc{left, right}, parser.spanFrom(start))
+			continue
+		}
+
 		left = &FcFnRetValue{
-			FcStr(curToken),
+			FcStr{curToken, optSpan},
 			[]TypeParamsAndParamsPair{{[]TypeVarStr{}, []Fc{left, right}}},
+			parser.spanFrom(start),
 		}
 	}
 
@@ -193,15 +267,29 @@ func (parser *Parser) parseFcUnaryExpr() (Fc, error) {
 		return nil, &parserErr{err, parser}
 	}
 
+	if cur := parser.currentNotation(); cur != nil && cur.decl.IsPrefix {
+		start := parser.getIndex()
+		symbol := parser.currentSpan()
+		parser.skip()
+		right, err := parser.parseFcInfixExpr(precUnary)
+		if err != nil {
+			return nil, err
+		}
+		return notationApplication(cur, symbol, []Fc{right}, parser.spanFrom(start)), nil
+	}
+
 	if prec, isUnary := unaryPrecedence[unaryOp]; isUnary {
+		start := parser.getIndex()
+		optSpan := parser.currentSpan()
 		parser.skip()
 		right, err := parser.parseFcInfixExpr(prec)
 		if err != nil {
 			return nil, err
 		}
 		return &FcFnRetValue{
-			FcStr(unaryOp),
+			FcStr{unaryOp, optSpan},
 			[]TypeParamsAndParamsPair{{[]TypeVarStr{}, []Fc{right}}},
+			parser.spanFrom(start),
 		}, nil
 	} else {
 		return parser.parseFcAtom()
@@ -210,45 +298,73 @@ func (parser *Parser) parseFcUnaryExpr() (Fc, error) {
 }
 
 func (parser *Parser) parseNumberStr() (FcStr, error) {
+	start := parser.getIndex()
 	left, err := parser.next()
 
 	if err != nil {
-		return "", err
+		return FcStr{}, err
 	}
 
 	if left[0] == '0' {
-		return "", fmt.Errorf("invalid number, 0 is not allowed in the first position of a number")
+		return FcStr{}, fmt.Errorf("invalid number, 0 is not allowed in the first position of a number")
 	}
 
 	_, err = strconv.Atoi(left)
 	if err != nil {
-		return "", fmt.Errorf("invalid number: %s", left)
+		return FcStr{}, fmt.Errorf("invalid number: %s", left)
 	}
 
 	if parser.is(BuiltinSyms["."]) {
 		// The member after . might be a member or a number
 		_, err := strconv.Atoi(parser.strAt(1))
 		if err != nil {
-			return FcStr(left), nil
+			return FcStr{left, parser.spanFrom(start)}, nil
 		} else {
 			parser.skip()
 			right, err := parser.next()
 
 			if err != nil {
-				return "", err
+				return FcStr{}, err
 			}
 			if err != nil {
-				return "", fmt.Errorf("invalid number: %s", right)
+				return FcStr{}, fmt.Errorf("invalid number: %s", right)
 			}
 
-			return FcStr(left) + "." + FcStr(right), nil
+			return FcStr{left + "." + right, parser.spanFrom(start)}, nil
 		}
 	}
 
-	return FcStr(left), nil
+	return FcStr{left, parser.spanFrom(start)}, nil
 }
 
-// func (parser *Parser) parseFcLambdaFn() (*FcLambdaFn, error) {
+// parseFcLambda parses fn(x R) => x ^ 2 or prop(x R) => x < 1.
+func (parser *Parser) parseFcLambda() (Fc, error) {
+	start := parser.getIndex()
+	isProp := parser.is(Keywords["prop"])
+	parser.skip()
+
+	params, err := parser.parseBracedFcStrTypePairArray()
+	if err != nil {
+		return nil, &parserErr{err, parser}
+	}
+	if err := parser.testAndSkip(BuiltinSyms["=>"]); err != nil {
+		return nil, &parserErr{err, parser}
+	}
+
+	if !isProp {
+		body, err := parser.ParseFc()
+		if err != nil {
+			return nil, &parserErr{err, parser}
+		}
+		return &FcLambdaFn{*params, body, parser.spanFrom(start)}, nil
+	}
 
-// 	return nil, nil
-// }
+	// the body of a prop lambda is a fact, which is parsed as a statement header
+	block := TokenBlock{Header: Parser{parser.index, parser.slice}}
+	body, err := block.parseInstantiatedFactStmt()
+	parser.index = block.Header.index
+	if err != nil {
+		return nil, &parserErr{err, parser}
+	}
+	return &FcLambdaProp{*params, body, parser.spanFrom(start)}, nil
+}
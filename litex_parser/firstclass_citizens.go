@@ -2,16 +2,20 @@ package litexparser
 
 import (
 	"fmt"
+	"strings"
 )
 
 type Fc interface {
 	fc()
 	String() string
+	GetSpan() Span
 }
 
 func (f FcStr) fc()         {}
 func (f *FcFnRetValue) fc() {}
 func (f *FcMemChain) fc()   {}
+func (f *FcLambdaFn) fc()   {}
+func (f *FcLambdaProp) fc() {}
 
 type TypeParamsAndParamsPair struct {
 	TypeParams []TypeVarStr
@@ -21,6 +25,7 @@ type TypeParamsAndParamsPair struct {
 type FcFnRetValue struct {
 	FnName                   FcStr
 	TypeParamsVarParamsPairs []TypeParamsAndParamsPair
+	Span                     Span
 }
 
 // type FcFnRetValue struct {
@@ -32,7 +37,15 @@ type FcFnRetValue struct {
 // used for variables that are returned by called function
 
 func (f *FcFnRetValue) String() string {
-	outPut := ""
+	if prec, ok := fcPrecedence(f); ok {
+		varParams := f.TypeParamsVarParamsPairs[0].VarParams
+		if len(varParams) == 2 {
+			return fmt.Sprintf("%s %s %s", fcOperandString(varParams[0], prec, rightAssociative[f.FnName.Value]), f.FnName, fcOperandString(varParams[1], prec, !rightAssociative[f.FnName.Value]))
+		}
+		return fmt.Sprintf("%s%s", f.FnName, fcOperandString(varParams[0], prec, true))
+	}
+
+	outPut := f.FnName.Value
 
 	for _, pair := range f.TypeParamsVarParamsPairs {
 		if len(pair.TypeParams) > 0 {
@@ -45,13 +58,15 @@ func (f *FcFnRetValue) String() string {
 			outPut += "]"
 		}
 
-		if len(pair.VarParams) > 0 {
+		if len(pair.VarParams) > 0 || len(pair.TypeParams) == 0 {
 			outPut += "("
 			for i := 0; i < len(pair.VarParams)-1; i++ {
 				outPut += pair.VarParams[i].String()
 				outPut += ", "
 			}
-			outPut += pair.VarParams[len(pair.VarParams)-1].String()
+			if len(pair.VarParams) > 0 {
+				outPut += pair.VarParams[len(pair.VarParams)-1].String()
+			}
 			outPut += ")"
 		}
 	}
@@ -59,19 +74,85 @@ func (f *FcFnRetValue) String() string {
 	return outPut
 }
 
-type FcStr string
+// FcStr is a name or a number, e.g. x or 1.5.
+type FcStr struct {
+	Value string
+	Span  Span
+}
 
 func (f FcStr) String() string {
-	return string(f)
+	return f.Value
 }
 
 // used for variables that are returned by called function, e,g. f().g().h().  The chain is connected by dots
-type FcMemChain []Fc
+type FcMemChain struct {
+	Members []Fc
+	Span    Span
+}
 
 func (f *FcMemChain) String() string {
-	ret := ""
-	for i := 0; i < len(*f)-1; i++ {
-		ret += fmt.Sprintf("%s.", (*f)[i])
+	strs := make([]string, len(f.Members))
+	for i, fc := range f.Members {
+		strs[i] = fc.String()
+		if _, ok := fcPrecedence(fc); ok {
+			strs[i] = BuiltinSyms["("] + strs[i] + BuiltinSyms[")"]
+		}
+	}
+	return strings.Join(strs, BuiltinSyms["."])
+}
+
+// FcLambdaFn is an anonymous fn: fn(x R) => x ^ 2. See lambda.go.
+type FcLambdaFn struct {
+	Params []StrTypePair
+	Body   Fc
+	Span   Span
+}
+
+// FcLambdaProp is an anonymous prop: prop(x R) => x < 1.
+type FcLambdaProp struct {
+	Params []StrTypePair
+	Body   SpecFactStmt
+	Span   Span
+}
+
+func (f *FcLambdaFn) String() string {
+	return fmt.Sprintf("%s%s %s %s", Keywords["fn"], bracedStrTypePairs(f.Params), BuiltinSyms["=>"], f.Body)
+}
+
+func (f *FcLambdaProp) String() string {
+	return fmt.Sprintf("%s%s %s %s", Keywords["prop"], bracedStrTypePairs(f.Params), BuiltinSyms["=>"], f.Body)
+}
+
+// fcPrecedence returns the precedence of the operator which fc applies, if fc is written as an
+// infix or a prefix operator application. A lambda has the lowest precedence because its body
+// extends as far right as possible.
+func fcPrecedence(fc Fc) (FcInfixOptPrecedence, bool) {
+	switch fc.(type) {
+	case *FcLambdaFn, *FcLambdaProp:
+		return precLowest, true
+	}
+	f, ok := fc.(*FcFnRetValue)
+	if !ok || len(f.TypeParamsVarParamsPairs) != 1 || len(f.TypeParamsVarParamsPairs[0].TypeParams) != 0 {
+		return precLowest, false
+	}
+	switch len(f.TypeParamsVarParamsPairs[0].VarParams) {
+	case 2:
+		prec, ok := precedenceMap[f.FnName.Value]
+		return prec, ok
+	case 1:
+		prec, ok := unaryPrecedence[f.FnName.Value]
+		return prec, ok
+	}
+	return precLowest, false
+}
+
+// fcOperandString prints fc as an operand of an operator of precedence prec, with parentheses only
+// where the parser would group it otherwise. An operand of the same precedence is parenthesized
+// if parenthesizeEqual: the right operand of a left associative operator, the left operand of a
+// right associative one, and the operand of a prefix operator.
+func fcOperandString(fc Fc, prec FcInfixOptPrecedence, parenthesizeEqual bool) string {
+	if cur, ok := fcPrecedence(fc); ok && (cur < prec || (parenthesizeEqual && cur == prec)) {
+		return BuiltinSyms["("] + fc.String() + BuiltinSyms[")"]
 	}
-	return ret + (*f)[len(*f)-1].String()
+	return fc.String()
 }
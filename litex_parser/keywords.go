@@ -1,7 +1,5 @@
 package litexparser
 
-import "sort"
-
 var BuiltinSyms = map[string]string{
 	":":     ":",
 	"[":     "[",
@@ -30,6 +28,9 @@ var BuiltinSyms = map[string]string{
 	"||":    "||",
 	"==":    "==",
 	"!=":    "!=",
+	"<=":    "<=",
+	">=":    ">=",
+	"%":     "%",
 	"\\":    "\\",
 	"?":     "?",
 	"**":    "**",
@@ -48,12 +49,27 @@ var BuiltinSyms = map[string]string{
 	"@":     "@", // v@n represents v[n]
 }
 
+// UnicodeAliases maps mathematical symbols to the keywords and builtin symbols they stand for. The
+// tokenizer reads an alias as what it stands for, so ∀ x R: is forall x R:.
+var UnicodeAliases = map[string]string{
+	"∀": "forall",
+	"∈": "\\in",
+	"≤": "<=",
+	"≥": ">=",
+	"≠": "!=",
+	"¬": "not",
+	"→": "=>",
+	"∧": "&&",
+	"∨": "||",
+	"↦": "=>",
+}
+
 var CustomizableOperators = map[string]string{
 	"/":     "__div__",
 	"+":     "__add__",
 	"-":     "__sub__",
 	"*":     "__mul__",
-	"^":     "__xor__",
+	"^":     "__pow__",
 	"<":     "__lt__",
 	">":     "__gt__",
 	"!":     "__not__",
@@ -62,11 +78,12 @@ var CustomizableOperators = map[string]string{
 	"~":     "__invert__",
 	"++":    "__add_add__",
 	"--":    "__sub_sub__",
-	"&&":    "__and__",
+	"&&":    "__and_and__",
 	"||":    "__or_or__",
 	"==":    "__eq_eq__",
 	"!=":    "__ne__",
 	"**":    "__pow__",
+	"%":     "__mod__",
 	"<=":    "__lt_eq__",
 	">=":    "__gt_eq__",
 	"\\in":  "__in__",
@@ -107,6 +124,7 @@ func putBuiltinIntoKeywords() *map[string]string {
 		"prove_by_contradiction": "prove_by_contradiction",
 		"thm":                    "thm",
 		"if":                     "if",
+		"notation":               "notation",
 		// I should give user keyword commutative and associative otherwise Litex can not verify (v1 + v2)@k = v2@k + v1@k even we we know (v1 + v2)@k = v1@k + v2@k
 		"commutative": "commutative",
 		"associative": "associative",
@@ -120,39 +138,3 @@ func putBuiltinIntoKeywords() *map[string]string {
 }
 
 var Keywords map[string]string = *putBuiltinIntoKeywords()
-
-var sortedSymbols []string = sortKeywordSymbols()
-
-// 初始化排序后的符号列表
-func sortKeywordSymbols() []string {
-	symbols := make([]string, 0, len(BuiltinSyms))
-	for k := range BuiltinSyms {
-		symbols = append(symbols, k)
-	}
-	sort.Slice(symbols, func(i, j int) bool {
-		return len(symbols[i]) > len(symbols[j])
-	})
-	return symbols
-}
-
-// 缓存排序后的符号列表
-
-// 如果输入字符串从起始位置开始是符号，则返回该符号
-func getKeywordSymbol(inputString string, start int) string {
-	for _, k := range sortedSymbols {
-		end := start + len(k)
-		if end <= len(inputString) {
-			match := true
-			for i := 0; i < len(k); i++ {
-				if inputString[start+i] != k[i] {
-					match = false
-					break
-				}
-			}
-			if match {
-				return k
-			}
-		}
-	}
-	return ""
-}
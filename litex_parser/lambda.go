@@ -0,0 +1,384 @@
+package litexparser
+
+import "fmt"
+
+// A lambda is an anonymous fn or prop, written with => or ↦:
+//
+//	fn(x R) => x ^ 2
+//	prop(n N) => n >= 1
+//
+// The body of a fn lambda is an Fc and the body of a prop lambda a spec fact. A lambda is applied
+// when it is bound to a parameter of a universal fact which the fact calls: instantiating $P(a)
+// with P bound to prop(n N) => n >= 1 gives a >= 1, and f(a) with f bound to fn(x R) => x ^ 2
+// gives a ^ 2. This is beta-reduction: the arguments are substituted for the parameters in the
+// body. Parameters of inner lambdas which would capture a name of the arguments are renamed. The
+// types of lambda parameters are not checked against the arguments.
+
+// Apply substitutes args for the parameters of f in its body.
+func (f *FcLambdaFn) Apply(args []Fc) (Fc, error) {
+	subst, err := lambdaSubst(f.Params, args)
+	if err != nil {
+		return nil, err
+	}
+	return SubstituteFc(f.Body, subst)
+}
+
+// Apply substitutes args for the parameters of f in its body.
+func (f *FcLambdaProp) Apply(args []Fc) (SpecFactStmt, error) {
+	subst, err := lambdaSubst(f.Params, args)
+	if err != nil {
+		return nil, err
+	}
+	return SubstituteSpecFact(f.Body, subst)
+}
+
+func lambdaSubst(params []StrTypePair, args []Fc) (map[string]Fc, error) {
+	if len(params) != len(args) {
+		return nil, fmt.Errorf("the lambda takes %d parameters, but is given %d", len(params), len(args))
+	}
+	ret := make(map[string]Fc, len(params))
+	for i, param := range params {
+		ret[param.Var] = args[i]
+	}
+	return ret, nil
+}
+
+// lambdaArgs returns the arguments of f, a call of a name bound to a lambda.
+func lambdaArgs(f *FcFnRetValue) ([]Fc, error) {
+	if len(f.TypeParamsVarParamsPairs) != 1 || len(f.TypeParamsVarParamsPairs[0].TypeParams) != 0 {
+		return nil, fmt.Errorf("%s is bound to a lambda, which takes one list of parameters and no type parameters", f.FnName)
+	}
+	return f.TypeParamsVarParamsPairs[0].VarParams, nil
+}
+
+// SubstituteFc returns fc with each free name in subst replaced by its value. A call of a name
+// which is replaced by a fn lambda is beta-reduced. fc is not changed.
+func SubstituteFc(fc Fc, subst map[string]Fc) (Fc, error) {
+	switch fc := fc.(type) {
+	case FcStr:
+		if value, ok := subst[fc.Value]; ok {
+			return value, nil
+		}
+		return fc, nil
+
+	case *FcFnRetValue:
+		ret := &FcFnRetValue{fc.FnName, make([]TypeParamsAndParamsPair, len(fc.TypeParamsVarParamsPairs)), fc.Span}
+		for i, pair := range fc.TypeParamsVarParamsPairs {
+			varParams, err := substituteFcSlice(pair.VarParams, subst)
+			if err != nil {
+				return nil, err
+			}
+			ret.TypeParamsVarParamsPairs[i] = TypeParamsAndParamsPair{pair.TypeParams, varParams}
+		}
+
+		value, ok := subst[fc.FnName.Value]
+		if !ok {
+			return ret, nil
+		}
+		switch value := value.(type) {
+		case FcStr:
+			ret.FnName = value
+			return ret, nil
+		case *FcLambdaFn:
+			args, err := lambdaArgs(ret)
+			if err != nil {
+				return nil, err
+			}
+			return value.Apply(args)
+		}
+		return nil, fmt.Errorf("%s is bound to %s, which is not a fn", fc.FnName, value)
+
+	case *FcMemChain:
+		members, err := substituteFcSlice(fc.Members, subst)
+		if err != nil {
+			return nil, err
+		}
+		return &FcMemChain{members, fc.Span}, nil
+
+	case *FcLambdaFn:
+		params, inner := substituteUnderParams(fc.Params, fc.Body, subst)
+		body, err := SubstituteFc(fc.Body, inner)
+		if err != nil {
+			return nil, err
+		}
+		return &FcLambdaFn{params, body, fc.Span}, nil
+
+	case *FcLambdaProp:
+		params, inner := substituteUnderParams(fc.Params, fc.Body, subst)
+		body, err := SubstituteSpecFact(fc.Body, inner)
+		if err != nil {
+			return nil, err
+		}
+		return &FcLambdaProp{params, body, fc.Span}, nil
+	}
+
+	return nil, fmt.Errorf("unknown Fc type: %T", fc)
+}
+
+func substituteFcSlice(fcs []Fc, subst map[string]Fc) ([]Fc, error) {
+	ret := make([]Fc, len(fcs))
+	for i, fc := range fcs {
+		cur, err := SubstituteFc(fc, subst)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = cur
+	}
+	return ret, nil
+}
+
+// SubstituteSpecFact returns fact with each free name in subst replaced by its value, like
+// SubstituteFc. A function fact calling a name which is replaced by a prop lambda is
+// beta-reduced, so not $P(a) with P bound to prop(n N) => n >= 1 gives not a >= 1.
+func SubstituteSpecFact(fact SpecFactStmt, subst map[string]Fc) (SpecFactStmt, error) {
+	switch fact := fact.(type) {
+	case *FuncFactStmt:
+		if call, ok := fact.Fc.(*FcFnRetValue); ok {
+			if lambda, ok := subst[call.FnName.Value].(*FcLambdaProp); ok {
+				args, err := lambdaArgs(call)
+				if err != nil {
+					return nil, err
+				}
+				if args, err = substituteFcSlice(args, subst); err != nil {
+					return nil, err
+				}
+				ret, err := lambda.Apply(args)
+				if err != nil {
+					return nil, err
+				}
+				if !fact.IsTrue {
+					ret.notFactStmtSetT(!specFactIsTrue(ret))
+				}
+				return ret, nil
+			}
+		}
+
+		fc, err := SubstituteFc(fact.Fc, subst)
+		if err != nil {
+			return nil, err
+		}
+		return &FuncFactStmt{fact.IsTrue, fc, fact.Span}, nil
+
+	case *RelationFactStmt:
+		vars, err := substituteFcSlice(fact.Vars, subst)
+		if err != nil {
+			return nil, err
+		}
+		return &RelationFactStmt{fact.IsTrue, vars, fact.Opt, fact.Span}, nil
+	}
+
+	return nil, fmt.Errorf("unknown SpecFactStmt type: %T", fact)
+}
+
+// SubstituteFact is SubstituteSpecFact for any fact. The parameters of a universal fact hide the
+// names they bind, and are renamed if they would capture a name of the values of subst.
+func SubstituteFact(fact FactStmt, subst map[string]Fc) (FactStmt, error) {
+	switch fact := fact.(type) {
+	case SpecFactStmt:
+		return SubstituteSpecFact(fact, subst)
+
+	case *IfFactStmt:
+		cond, err := substituteFacts(fact.CondFacts, subst)
+		if err != nil {
+			return nil, err
+		}
+		then, err := substituteSpecFacts(fact.ThenFacts, subst)
+		if err != nil {
+			return nil, err
+		}
+		return &IfFactStmt{cond, then, fact.Span}, nil
+
+	case *BlockForallStmt:
+		params, inner := substituteUnderParams(fact.VarParams, fact, subst)
+		cond, err := substituteFacts(fact.Cond, inner)
+		if err != nil {
+			return nil, err
+		}
+		then, err := substituteSpecFacts(fact.Then, inner)
+		if err != nil {
+			return nil, err
+		}
+		return &BlockForallStmt{fact.TypeParams, params, cond, then, fact.Span}, nil
+	}
+
+	return nil, fmt.Errorf("unknown FactStmt type: %T", fact)
+}
+
+func substituteFacts(facts []FactStmt, subst map[string]Fc) ([]FactStmt, error) {
+	ret := make([]FactStmt, len(facts))
+	for i, fact := range facts {
+		cur, err := SubstituteFact(fact, subst)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = cur
+	}
+	return ret, nil
+}
+
+func substituteSpecFacts(facts []SpecFactStmt, subst map[string]Fc) ([]SpecFactStmt, error) {
+	ret := make([]SpecFactStmt, len(facts))
+	for i, fact := range facts {
+		cur, err := SubstituteSpecFact(fact, subst)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = cur
+	}
+	return ret, nil
+}
+
+func specFactIsTrue(fact SpecFactStmt) bool {
+	switch fact := fact.(type) {
+	case *FuncFactStmt:
+		return fact.IsTrue
+	case *RelationFactStmt:
+		return fact.IsTrue
+	}
+	return true
+}
+
+// substituteUnderParams returns the parameters of a lambda with body, and the substitution to
+// apply to body. The parameters hide the names they bind from subst, and a parameter whose name
+// appears in a value of subst is renamed so that it does not capture that name.
+func substituteUnderParams(params []StrTypePair, body any, subst map[string]Fc) ([]StrTypePair, map[string]Fc) {
+	inner := make(map[string]Fc, len(subst))
+	for name, value := range subst {
+		inner[name] = value
+	}
+	for _, param := range params {
+		delete(inner, param.Var)
+	}
+
+	free := map[string]bool{}
+	for _, value := range inner {
+		collectNames(value, free)
+	}
+	ret := make([]StrTypePair, len(params))
+	copy(ret, params)
+	var taken map[string]bool // names a renamed parameter must not take
+	for i, param := range params {
+		if !free[param.Var] {
+			continue
+		}
+		if taken == nil {
+			taken = map[string]bool{}
+			collectNames(body, taken)
+			for name := range free {
+				taken[name] = true
+			}
+			for _, other := range params {
+				taken[other.Var] = true
+			}
+		}
+		fresh := param.Var
+		for n := 1; taken[fresh]; n++ {
+			fresh = fmt.Sprintf("%s_%d", param.Var, n)
+		}
+		taken[fresh] = true
+		ret[i].Var = fresh
+		inner[param.Var] = FcStr{Value: fresh}
+	}
+	return ret, inner
+}
+
+func collectNames(node any, names map[string]bool) {
+	Inspect(node, func(node any) bool {
+		if name, ok := node.(FcStr); ok {
+			names[name.Value] = true
+		}
+		return true
+	})
+}
+
+// CanonicalFc returns fc with the parameters of its lambdas renamed after their depth: #0 for the
+// first parameter of the outermost lambda. Lambdas which differ only in the names of their
+// parameters have the same canonical form.
+func CanonicalFc(fc Fc) Fc {
+	ret, err := canonicalFc(fc, 0)
+	if err != nil {
+		return fc
+	}
+	return ret
+}
+
+func canonicalFc(fc Fc, depth int) (Fc, error) {
+	switch fc := fc.(type) {
+	case *FcFnRetValue:
+		ret := &FcFnRetValue{fc.FnName, make([]TypeParamsAndParamsPair, len(fc.TypeParamsVarParamsPairs)), fc.Span}
+		for i, pair := range fc.TypeParamsVarParamsPairs {
+			varParams, err := canonicalFcSlice(pair.VarParams, depth)
+			if err != nil {
+				return nil, err
+			}
+			ret.TypeParamsVarParamsPairs[i] = TypeParamsAndParamsPair{pair.TypeParams, varParams}
+		}
+		return ret, nil
+
+	case *FcMemChain:
+		members, err := canonicalFcSlice(fc.Members, depth)
+		if err != nil {
+			return nil, err
+		}
+		return &FcMemChain{members, fc.Span}, nil
+
+	case *FcLambdaFn:
+		params, subst := canonicalParams(fc.Params, depth)
+		body, err := SubstituteFc(fc.Body, subst)
+		if err != nil {
+			return nil, err
+		}
+		if body, err = canonicalFc(body, depth+len(params)); err != nil {
+			return nil, err
+		}
+		return &FcLambdaFn{params, body, fc.Span}, nil
+
+	case *FcLambdaProp:
+		params, subst := canonicalParams(fc.Params, depth)
+		body, err := SubstituteSpecFact(fc.Body, subst)
+		if err != nil {
+			return nil, err
+		}
+		switch cur := body.(type) {
+		case *FuncFactStmt:
+			canonical, err := canonicalFc(cur.Fc, depth+len(params))
+			if err != nil {
+				return nil, err
+			}
+			body = &FuncFactStmt{cur.IsTrue, canonical, cur.Span}
+		case *RelationFactStmt:
+			vars, err := canonicalFcSlice(cur.Vars, depth+len(params))
+			if err != nil {
+				return nil, err
+			}
+			body = &RelationFactStmt{cur.IsTrue, vars, cur.Opt, cur.Span}
+		}
+		return &FcLambdaProp{params, body, fc.Span}, nil
+	}
+	return fc, nil
+}
+
+func canonicalFcSlice(fcs []Fc, depth int) ([]Fc, error) {
+	ret := make([]Fc, len(fcs))
+	for i, fc := range fcs {
+		cur, err := canonicalFc(fc, depth)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = cur
+	}
+	return ret, nil
+}
+
+// canonicalParams names params #depth, #depth+1, ...; names starting with # can not be written
+// in source code, so the renaming captures nothing.
+func canonicalParams(params []StrTypePair, depth int) ([]StrTypePair, map[string]Fc) {
+	ret := make([]StrTypePair, len(params))
+	subst := make(map[string]Fc, len(params))
+	for i, param := range params {
+		name := fmt.Sprintf("%s%d", BuiltinSyms["#"], depth+i)
+		ret[i] = StrTypePair{name, param.Type}
+		subst[param.Var] = FcStr{Value: name}
+	}
+	return ret, subst
+}
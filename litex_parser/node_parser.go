@@ -19,7 +19,7 @@ func (parser *Parser) parseBracedFcArr() (*[]Fc, error) {
 
 		if !parser.is(BuiltinSyms[","]) {
 			if !parser.is(BuiltinSyms[")"]) {
-				return nil, &parserErr{err, parser}
+				return nil, &parserErr{fmt.Errorf("expected ',' or ')'"), parser}
 			} else {
 				break
 			}
@@ -59,6 +59,10 @@ func (parser *Parser) parseFcVarTypePairArrEndWithColon() (*[]StrTypePair, error
 		}
 	}
 
+	if len(pairs) == 0 {
+		parser.skip(BuiltinSyms[":"])
+	}
+
 	return &pairs, nil
 }
 
@@ -133,7 +137,7 @@ func (parser *Parser) parseFcFnDecl() (*FcFnDecl, error) {
 	return &FcFnDecl{name, FcFnType{*typeParamsTypes, *varParamsTypes, retType}}, nil
 }
 
-func (parser *Parser) parseFcType() (fcType, error) {
+func (parser *Parser) parseFcType() (FcType, error) {
 	if parser.is(BuiltinSyms["?"]) {
 		return parser.parseUndefinedFcType()
 	}
@@ -154,10 +158,10 @@ func (parser *Parser) parseUndefinedFcType() (fcUndefinedType, error) {
 		return undefinedFnTypeInstance, nil
 	} else if parser.is(Keywords["prop"]) {
 		parser.skip()
-		return undefinedVarTypeInstance, nil
+		return undefinedPropTypeInstance, nil
 	} else if parser.is(Keywords["var"]) {
 		parser.skip()
-		return undefinedPropTypeInstance, nil
+		return undefinedVarTypeInstance, nil
 	}
 
 	return nil, &parserErr{fmt.Errorf("expect 'fn', 'prop', 'var' after '?'"), parser}
@@ -261,6 +265,10 @@ func (parser *Parser) parseBracketedTypeConceptPairArray() (*[]TypeConceptPair,
 		}
 	}
 
+	if len(concepts) == 0 {
+		parser.skip(BuiltinSyms["]"])
+	}
+
 	return &concepts, nil
 }
 
@@ -290,6 +298,10 @@ func (parser *Parser) parseBracedFcStrTypePairArray() (*[]StrTypePair, error) {
 		}
 	}
 
+	if len(pairs) == 0 {
+		parser.skip(BuiltinSyms[")"])
+	}
+
 	return &pairs, nil
 }
 
@@ -371,12 +383,13 @@ func (parser *Parser) parseStringArrUntilEnd() (*[]string, error) {
 }
 
 func (parser *Parser) parseIsExpr(left Fc) (*FuncFactStmt, error) {
+	start := parser.getIndex()
 	err := parser.skip(Keywords["is"])
 	if err != nil {
 		return nil, &parserErr{err, parser}
 	}
 
-	opt, err := parser.next() // get the operator.
+	opt, err := parser.parseFcStr() // get the operator.
 
 	if err != nil {
 		return nil, &parserErr{err, parser}
@@ -394,7 +407,8 @@ func (parser *Parser) parseIsExpr(left Fc) (*FuncFactStmt, error) {
 		}
 	}
 
-	return &FuncFactStmt{true, &FcFnRetValue{FcStr(opt), []TypeParamsAndParamsPair{{*typeParams, []Fc{left}}}}}, nil
+	span := left.GetSpan().join(parser.spanFrom(start))
+	return &FuncFactStmt{true, &FcFnRetValue{opt, []TypeParamsAndParamsPair{{*typeParams, []Fc{left}}}, span}, span}, nil
 }
 
 func (parser *Parser) parseTypeVar() (TypeVarStr, error) {
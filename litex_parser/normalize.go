@@ -0,0 +1,254 @@
+package litexparser
+
+// An operator can be applied in operator form, a + b, or by calling its member function named in
+// CustomizableOperators, written __add__(a, b), a.__add__(b) or Real.__add__(a, b) where Real is
+// a type. Normalization turns every such call into operator form, so facts written either way are
+// compared equal, and a fact $__lt__(a, b) becomes the relation fact a < b. ** is written ^, as
+// both are exponentiation. Normalization returns new nodes and never changes its argument.
+
+// operatorAliases maps operators to the operator with the same meaning they are normalized to.
+var operatorAliases = map[string]string{
+	"**": "^",
+}
+
+// dunderOperators maps the member function of each customizable operator back to the operator.
+var dunderOperators = map[string]string{}
+
+func init() {
+	for opt, dunder := range CustomizableOperators {
+		if _, ok := operatorAliases[opt]; !ok {
+			dunderOperators[dunder] = opt
+		}
+	}
+}
+
+// normalizer tells type names apart from other names, which a member function call may be
+// qualified with.
+type normalizer struct {
+	isType func(name string) bool
+}
+
+// NormalizeFc returns fc with every operator written as a member function call in operator form.
+// isType tells whether a name is a type name; nil if no name is.
+func NormalizeFc(fc Fc, isType func(name string) bool) Fc {
+	return normalizer{isType}.fc(fc)
+}
+
+// NormalizeFact returns fact with its Fcs normalized by NormalizeFc, and with each function fact
+// applying a relational operator written as a relation fact.
+func NormalizeFact(fact FactStmt, isType func(name string) bool) FactStmt {
+	return normalizer{isType}.fact(fact)
+}
+
+func (n normalizer) isTypeName(fc Fc) bool {
+	name, ok := fc.(FcStr)
+	return ok && n.isType != nil && n.isType(name.Value)
+}
+
+func (n normalizer) fc(fc Fc) Fc {
+	switch fc := fc.(type) {
+	case *FcFnRetValue:
+		pairs := make([]TypeParamsAndParamsPair, len(fc.TypeParamsVarParamsPairs))
+		for i, pair := range fc.TypeParamsVarParamsPairs {
+			pairs[i] = TypeParamsAndParamsPair{pair.TypeParams, n.fcSlice(pair.VarParams)}
+		}
+		ret := &FcFnRetValue{fc.FnName, pairs, fc.Span}
+		if opt, ok := operatorAliases[fc.FnName.Value]; ok {
+			ret.FnName = FcStr{opt, fc.FnName.Span}
+		} else if opt, ok := dunderCallOperator(ret, 0); ok {
+			ret.FnName = FcStr{opt, fc.FnName.Span}
+		}
+		return ret
+	case *FcMemChain:
+		chain := &FcMemChain{n.fcSlice(fc.Members), fc.Span}
+		if len(chain.Members) < 2 {
+			return chain
+		}
+		original, ok := fc.Members[len(fc.Members)-1].(*FcFnRetValue)
+		if !ok {
+			return chain
+		}
+		// the member function keeps its name unless the chain is written in operator form
+		call := chain.Members[len(chain.Members)-1].(*FcFnRetValue)
+		call.FnName = original.FnName
+		if len(chain.Members) == 2 && n.isTypeName(chain.Members[0]) {
+			// Real.__lt__(1, 2) is 1 < 2
+			if opt, ok := dunderCallOperator(call, 0); ok {
+				return &FcFnRetValue{FcStr{opt, call.FnName.Span}, call.TypeParamsVarParamsPairs, call.Span}
+			}
+			return chain
+		}
+		// x.__sub__(y) is x - y
+		opt, ok := dunderCallOperator(call, 1)
+		if !ok {
+			return chain
+		}
+		receiver := chain.Members[0]
+		if len(chain.Members) > 2 {
+			prefix := chain.Members[:len(chain.Members)-1]
+			receiver = &FcMemChain{prefix, prefix[0].GetSpan().join(prefix[len(prefix)-1].GetSpan())}
+		}
+		params := append([]Fc{receiver}, call.TypeParamsVarParamsPairs[0].VarParams...)
+		return &FcFnRetValue{FcStr{opt, call.FnName.Span}, []TypeParamsAndParamsPair{{nil, params}}, chain.Span}
+	case *FcLambdaFn:
+		return &FcLambdaFn{fc.Params, n.fc(fc.Body), fc.Span}
+	case *FcLambdaProp:
+		return &FcLambdaProp{fc.Params, n.specFact(fc.Body), fc.Span}
+	}
+	return fc
+}
+
+func (n normalizer) fcSlice(fcs []Fc) []Fc {
+	if fcs == nil {
+		return nil
+	}
+	ret := make([]Fc, len(fcs))
+	for i, fc := range fcs {
+		ret[i] = n.fc(fc)
+	}
+	return ret
+}
+
+// dunderCallOperator returns the operator which f applies if f calls the member function of an
+// operator with as many parameters as the operator takes, besides the given number of receivers.
+// The member function of an operator which is both infix and prefix, like -, takes two operands.
+func dunderCallOperator(f *FcFnRetValue, receivers int) (string, bool) {
+	opt, ok := dunderOperators[f.FnName.Value]
+	if !ok || len(f.TypeParamsVarParamsPairs) != 1 || len(f.TypeParamsVarParamsPairs[0].TypeParams) != 0 {
+		return "", false
+	}
+	switch len(f.TypeParamsVarParamsPairs[0].VarParams) + receivers {
+	case 2:
+		_, ok = precedenceMap[opt]
+	case 1:
+		_, isInfix := precedenceMap[opt]
+		_, ok = unaryPrecedence[opt]
+		ok = ok && !isInfix
+	default:
+		ok = false
+	}
+	return opt, ok
+}
+
+func (n normalizer) fact(fact FactStmt) FactStmt {
+	switch fact := fact.(type) {
+	case SpecFactStmt:
+		return n.specFact(fact)
+	case *BlockForallStmt:
+		return &BlockForallStmt{fact.TypeParams, fact.VarParams, n.facts(fact.Cond), n.specFacts(fact.Then), fact.Span}
+	case *IfFactStmt:
+		return &IfFactStmt{n.facts(fact.CondFacts), n.specFacts(fact.ThenFacts), fact.Span}
+	}
+	return fact
+}
+
+func (n normalizer) specFact(fact SpecFactStmt) SpecFactStmt {
+	switch fact := fact.(type) {
+	case *RelationFactStmt:
+		return &RelationFactStmt{fact.IsTrue, n.fcSlice(fact.Vars), fact.Opt, fact.Span}
+	case *FuncFactStmt:
+		fc := n.fc(fact.Fc)
+		if call, ok := fc.(*FcFnRetValue); ok && isBuiltinRelationalOperator(call.FnName.Value) {
+			if _, ok := fcPrecedence(call); ok {
+				return &RelationFactStmt{fact.IsTrue, call.TypeParamsVarParamsPairs[0].VarParams, call.FnName, fact.Span}
+			}
+		}
+		return &FuncFactStmt{fact.IsTrue, fc, fact.Span}
+	}
+	return fact
+}
+
+func (n normalizer) facts(facts []FactStmt) []FactStmt {
+	if facts == nil {
+		return nil
+	}
+	ret := make([]FactStmt, len(facts))
+	for i, fact := range facts {
+		ret[i] = n.fact(fact)
+	}
+	return ret
+}
+
+func (n normalizer) specFacts(facts []SpecFactStmt) []SpecFactStmt {
+	if facts == nil {
+		return nil
+	}
+	ret := make([]SpecFactStmt, len(facts))
+	for i, fact := range facts {
+		ret[i] = n.specFact(fact)
+	}
+	return ret
+}
+
+// stmt returns stmt with the facts it contains normalized, down to the statements of its proofs.
+// Statements which contain no facts are returned as they are.
+func (n normalizer) stmt(stmt Stmt) Stmt {
+	switch stmt := stmt.(type) {
+	case FactStmt:
+		return n.fact(stmt)
+	case *KnowStmt:
+		ret := *stmt
+		ret.Facts = n.facts(stmt.Facts)
+		return &ret
+	case *DefPropStmt:
+		ret := *stmt
+		ret.IfFacts, ret.ThenFacts = n.facts(stmt.IfFacts), n.facts(stmt.ThenFacts)
+		return &ret
+	case *DefExistStmt:
+		ret := *stmt
+		ret.IfFacts, ret.ThenFacts = n.facts(stmt.IfFacts), n.facts(stmt.ThenFacts)
+		return &ret
+	case *DefFnStmt:
+		ret := *stmt
+		ret.IfFacts, ret.ThenFacts = n.facts(stmt.IfFacts), n.facts(stmt.ThenFacts)
+		return &ret
+	case *DefTypeStmt:
+		ret := *stmt
+		ret.ThenFacts = n.facts(stmt.ThenFacts)
+		return &ret
+	case *DefConceptStmt:
+		ret := *stmt
+		ret.ThenFacts = n.facts(stmt.ThenFacts)
+		return &ret
+	case *DefMemberStmt:
+		ret := *stmt
+		ret.Facts = n.facts(stmt.Facts)
+		return &ret
+	case *DefTypeMemberStmt:
+		ret := *stmt
+		ret.Facts = n.facts(stmt.Facts)
+		return &ret
+	case *HaveStmt:
+		ret := *stmt
+		ret.PropStmt = n.specFact(stmt.PropStmt)
+		return &ret
+	case *ClaimProveStmt:
+		ret := *stmt
+		ret.ToCheck, ret.Proof = n.facts(stmt.ToCheck), n.stmts(stmt.Proof)
+		return &ret
+	case *ClaimProveByContradictStmt:
+		ret := *stmt
+		ret.ToCheck, ret.Proof = n.facts(stmt.ToCheck), n.stmts(stmt.Proof)
+		return &ret
+	case *AxiomStmt:
+		ret := *stmt
+		ret.Decl = n.stmt(stmt.Decl).(DefPropExistDeclStmt)
+		return &ret
+	case *ThmStmt:
+		ret := *stmt
+		ret.Decl, ret.Proof = n.stmt(stmt.Decl).(DefPropExistDeclStmt), n.stmts(stmt.Proof)
+		return &ret
+	}
+	return stmt
+}
+
+func (n normalizer) stmts(stmts []Stmt) []Stmt {
+	if stmts == nil {
+		return nil
+	}
+	ret := make([]Stmt, len(stmts))
+	for i, stmt := range stmts {
+		ret[i] = n.stmt(stmt)
+	}
+	return ret
+}
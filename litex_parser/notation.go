@@ -0,0 +1,227 @@
+package litexparser
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A notation declares an operator symbol which stands for a fn or a prop:
+//
+//	notation infix ∘ fn compose precedence * right
+//	notation infix ≺ prop precedes
+//	notation prefix √ fn sqrt
+//
+// a ∘ b parses as compose(a, b), and the fact a ≺ b as $precedes(a, b). An infix fn notation has
+// the precedence of the operator after precedence, + by default, and is left associative unless
+// declared right. An infix prop notation is a relation: it has the precedence of comparisons.
+// Prefix notations have the precedence of the other prefix operators.
+//
+// Notations are declared by top-level statements and apply to the statements after them. A file
+// which imports another declares the pub notations of that file with NotationTable.Import before
+// it is parsed.
+
+// NotationTable holds the notations declared while parsing source code.
+type NotationTable struct {
+	notations map[string]*notation
+	symbols   *symbolTrie // symbols of notations
+}
+
+type notation struct {
+	decl       *DefNotationStmt
+	precedence FcInfixOptPrecedence
+	isPub      bool
+}
+
+func NewNotationTable() *NotationTable {
+	return &NotationTable{notations: map[string]*notation{}, symbols: newSymbolTrie(nil)}
+}
+
+func (t *NotationTable) add(cur *notation) {
+	t.notations[cur.decl.Symbol] = cur
+	t.symbols.insert(cur.decl.Symbol)
+}
+
+// Declare makes the notation of stmt apply to the source code parsed with t from now on.
+func (t *NotationTable) Declare(stmt *DefNotationStmt, isPub bool) error {
+	if stmt.Symbol == "" || strings.ContainsAny(stmt.Symbol, " \t") {
+		return fmt.Errorf("invalid notation symbol '%s'", stmt.Symbol)
+	}
+	if _, ok := Keywords[stmt.Symbol]; ok {
+		return fmt.Errorf("'%s' is a keyword or a builtin symbol", stmt.Symbol)
+	}
+	// the name x would be taken as an operator wherever it is in infix position
+	if r, _ := utf8.DecodeRuneInString(stmt.Symbol); isIdentifierRune(r) {
+		return fmt.Errorf("notation symbol '%s' starts like an identifier", stmt.Symbol)
+	}
+	if alias, ok := UnicodeAliases[stmt.Symbol]; ok {
+		return fmt.Errorf("'%s' is an alias of '%s'", stmt.Symbol, alias)
+	}
+	if _, ok := t.notations[stmt.Symbol]; ok {
+		return fmt.Errorf("notation '%s' is already declared", stmt.Symbol)
+	}
+
+	ret := &notation{decl: stmt, precedence: precUnary, isPub: isPub}
+	switch {
+	case stmt.IsPrefix:
+		if stmt.PrecedenceOf != "" || stmt.IsRightAssoc {
+			return fmt.Errorf("prefix notation '%s' can not have a precedence or an associativity", stmt.Symbol)
+		}
+	case stmt.IsProp:
+		if stmt.PrecedenceOf != "" || stmt.IsRightAssoc {
+			return fmt.Errorf("infix prop notation '%s' is a relation and can not have a precedence or an associativity", stmt.Symbol)
+		}
+		ret.precedence = precComparison
+	default:
+		ret.precedence = precAddition
+		if stmt.PrecedenceOf != "" {
+			prec, ok := t.infixPrecedence(stmt.PrecedenceOf)
+			if !ok {
+				return fmt.Errorf("'%s' is not an infix operator", stmt.PrecedenceOf)
+			}
+			ret.precedence = prec
+		}
+	}
+
+	t.add(ret)
+	return nil
+}
+
+// clone returns a copy of t, so that declaring notations in the copy does not change t.
+func (t *NotationTable) clone() *NotationTable {
+	ret := NewNotationTable()
+	for _, cur := range t.notations {
+		ret.add(cur)
+	}
+	return ret
+}
+
+// Import declares the pub notations of from, which are declared by an imported file.
+func (t *NotationTable) Import(from *NotationTable) error {
+	for _, cur := range from.notations {
+		if !cur.isPub {
+			continue
+		}
+		if _, ok := t.notations[cur.decl.Symbol]; ok {
+			return fmt.Errorf("notation '%s' is already declared", cur.decl.Symbol)
+		}
+		t.add(&notation{cur.decl, cur.precedence, false})
+	}
+	return nil
+}
+
+func (t *NotationTable) get(symbol string) *notation {
+	if t == nil {
+		return nil
+	}
+	return t.notations[symbol]
+}
+
+func (t *NotationTable) infixPrecedence(opt string) (FcInfixOptPrecedence, bool) {
+	if prec, ok := precedenceMap[opt]; ok {
+		return prec, true
+	}
+	if cur := t.get(opt); cur != nil && !cur.decl.IsPrefix {
+		return cur.precedence, true
+	}
+	return precLowest, false
+}
+
+// symbolAt returns the builtin symbol or the declared notation symbol which inputString has at
+// start, the longest if several match.
+func (t *NotationTable) symbolAt(inputString string, start int) string {
+	ret := builtinSymbols.longestMatch(inputString, start)
+	if t == nil {
+		return ret
+	}
+	if symbol := t.symbols.longestMatch(inputString, start); len(symbol) > len(ret) {
+		return symbol
+	}
+	return ret
+}
+
+func isWordSymbol(symbol string) bool {
+	for _, r := range symbol {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+// notationApplication builds the call of the fn or prop which a notation stands for. The name of
+// the fn or prop is located at the symbol of the notation.
+func notationApplication(cur *notation, symbol Span, params []Fc, span Span) *FcFnRetValue {
+	return &FcFnRetValue{FcStr{cur.decl.Binding, symbol}, []TypeParamsAndParamsPair{{[]TypeVarStr{}, params}}, span}
+}
+
+func (parser *Parser) currentNotation() *notation {
+	if parser.index >= len(parser.slice) {
+		return nil
+	}
+	return parser.slice[parser.index].notation
+}
+
+// parseNotationSymbol parses a symbol which may be split into several tokens, e.g. <+> before it
+// is declared: the symbol runs until the next space.
+func (parser *Parser) parseNotationSymbol() (string, error) {
+	start := parser.getIndex()
+	symbol, err := parser.next()
+	if err != nil {
+		return "", err
+	}
+	for !parser.ExceedEnd() && parser.slice[start].Span.IsValid() && parser.slice[parser.index].Span.Start == parser.slice[parser.index-1].Span.End {
+		symbol += parser.slice[parser.index].Value
+		parser.index++
+	}
+	return symbol, nil
+}
+
+func (stmt *TokenBlock) parseDefNotationStmt() (*DefNotationStmt, error) {
+	stmt.Header.skip(Keywords["notation"])
+
+	ret := &DefNotationStmt{}
+	kind, err := stmt.Header.next()
+	if err != nil {
+		return nil, &parseStmtErr{err, *stmt}
+	}
+	switch kind {
+	case "infix":
+	case "prefix":
+		ret.IsPrefix = true
+	default:
+		return nil, &parseStmtErr{fmt.Errorf("expected 'infix' or 'prefix', but got '%s'", kind), *stmt}
+	}
+
+	if ret.Symbol, err = stmt.Header.parseNotationSymbol(); err != nil {
+		return nil, &parseStmtErr{err, *stmt}
+	}
+
+	binding, err := stmt.Header.next()
+	if err != nil {
+		return nil, &parseStmtErr{err, *stmt}
+	}
+	if binding != Keywords["fn"] && binding != Keywords["prop"] {
+		return nil, &parseStmtErr{fmt.Errorf("expected '%s' or '%s', but got '%s'", Keywords["fn"], Keywords["prop"], binding), *stmt}
+	}
+	ret.IsProp = binding == Keywords["prop"]
+
+	if ret.Binding, err = stmt.Header.next(); err != nil {
+		return nil, &parseStmtErr{err, *stmt}
+	}
+
+	if stmt.Header.isAndSkip("precedence") {
+		if ret.PrecedenceOf, err = stmt.Header.parseNotationSymbol(); err != nil {
+			return nil, &parseStmtErr{err, *stmt}
+		}
+	}
+	if stmt.Header.isAndSkip("right") {
+		ret.IsRightAssoc = true
+	} else {
+		stmt.Header.isAndSkip("left")
+	}
+
+	ret.Span = stmt.span()
+	return ret, nil
+}
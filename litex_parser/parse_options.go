@@ -0,0 +1,121 @@
+package litexparser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ParseOptions tells Parse how to parse source code. The zero value parses code which is not read
+// from a file, with every feature and no notations of other files.
+type ParseOptions struct {
+	File   string // file the code is read from, which spans refer to; empty if it is not read from a file
+	Indent IndentPolicy
+	// notations of other files which apply to the code, e.g. imported ones; the notations which
+	// the code declares are added to it. nil for none.
+	Notations *NotationTable
+	// syntax features the code may not use; a statement which uses one is an error
+	DisabledFeatures Feature
+	// Trace, if not nil, is called after each top-level statement is parsed, with the statement,
+	// nil if it does not parse, and its errors, nil if it has none.
+	Trace func(stmt *TopStmt, err error)
+}
+
+// Feature is a set of syntax features which can be disabled, e.g. for code read by a tool which
+// does not support them.
+type Feature uint32
+
+const (
+	FeatureNotation Feature = 1 << iota // notation declarations
+	FeatureLambda                       // fn and prop lambdas
+)
+
+var featureNames = []string{"notation", "lambda"}
+
+func (f Feature) String() string {
+	names := []string{}
+	for i, name := range featureNames {
+		if f&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "no feature"
+	}
+	return strings.Join(names, "|")
+}
+
+// checkFeatures returns an error at the first use of a disabled feature in stmt.
+func (opts *ParseOptions) checkFeatures(stmt Stmt) error {
+	disabled := func(feature Feature, span Span) error {
+		return &Diagnostic{Code: DiagDisabledFeature, Severity: SeverityError, Message: fmt.Sprintf("%s is disabled", feature), Span: span}
+	}
+	if decl, ok := stmt.(*DefNotationStmt); ok && opts.DisabledFeatures&FeatureNotation != 0 {
+		return disabled(FeatureNotation, decl.Span)
+	}
+	if opts.DisabledFeatures&FeatureLambda == 0 {
+		return nil
+	}
+
+	var err error
+	Inspect(stmt, func(node any) bool {
+		switch node := node.(type) {
+		case *FcLambdaFn:
+			err = disabled(FeatureLambda, node.Span)
+		case *FcLambdaProp:
+			err = disabled(FeatureLambda, node.Span)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// FileSet holds the source code of the files parsed together, so that the positions of any of them
+// can be shown in their source, e.g. by Diagnostic.Render with Sources. A file is known by its
+// name, so parsing a file again replaces its source. A FileSet may be used by several goroutines.
+type FileSet struct {
+	mu      sync.RWMutex
+	sources map[string]string
+}
+
+func NewFileSet() *FileSet {
+	return &FileSet{sources: map[string]string{}}
+}
+
+func (s *FileSet) add(file string, code string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sources[file] = code
+}
+
+// Source returns the source code of file, "" for source code not read from a file.
+func (s *FileSet) Source(file string) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	code, ok := s.sources[file]
+	return code, ok
+}
+
+// Files returns the names of the files, sorted.
+func (s *FileSet) Files() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ret := make([]string, 0, len(s.sources))
+	for file := range s.sources {
+		ret = append(ret, file)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Sources returns the source code of every file by its name.
+func (s *FileSet) Sources() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ret := make(map[string]string, len(s.sources))
+	for file, code := range s.sources {
+		ret[file] = code
+	}
+	return ret
+}
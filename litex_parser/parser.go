@@ -7,7 +7,7 @@ import (
 
 type Parser struct {
 	index int
-	slice []string
+	slice []Token
 	// In many ways there should be one more field: err []string to store the error. It should be done this way because all parsing functions in parser is a method of parser, and if we do so, we can use nil as return value as the error indicator and never return 2 fields in each function.
 }
 
@@ -18,7 +18,7 @@ func (p *Parser) strAt(index int) string {
 		if i < 0 || i >= len(p.slice) {
 			return ""
 		} else {
-			return p.slice[i]
+			return p.slice[i].Value
 		}
 	} else {
 		i := len(p.slice) + index
@@ -26,14 +26,14 @@ func (p *Parser) strAt(index int) string {
 		if i < 0 || i >= len(p.slice) {
 			return ""
 		} else {
-			return p.slice[i]
+			return p.slice[i].Value
 		}
 	}
 
 }
 
 func (p *Parser) String() string {
-	return strings.Join(p.slice, " ")
+	return strings.Join(p.getSlice(), " ")
 }
 
 func (p *Parser) getIndex() int {
@@ -41,41 +41,80 @@ func (p *Parser) getIndex() int {
 }
 
 func (p *Parser) getSlice() []string {
-	return p.slice
+	ret := make([]string, len(p.slice))
+	for i, tok := range p.slice {
+		ret[i] = tok.Value
+	}
+	return ret
+}
+
+// currentSpan returns the span of the current token, or the empty span right after the last
+// token if the parser exceeds the end.
+func (p *Parser) currentSpan() Span {
+	if p.index < len(p.slice) {
+		return p.slice[p.index].Span
+	}
+	if len(p.slice) == 0 {
+		return Span{}
+	}
+	end := p.slice[len(p.slice)-1].Span
+	return Span{end.File, end.End, end.End}
+}
+
+// spanFrom returns the span from the token at index start to the last consumed token.
+func (p *Parser) spanFrom(start int) Span {
+	return p.spanBetween(start, p.index)
+}
+
+// spanBetween returns the span of tokens from index start up to, but not including, index end.
+func (p *Parser) spanBetween(start int, end int) Span {
+	end = min(end, len(p.slice))
+	if start >= end {
+		return p.currentSpan()
+	}
+	return p.slice[start].Span.join(p.slice[end-1].Span)
+}
+
+// span returns the span of all tokens of the parser.
+func (p *Parser) span() Span {
+	if len(p.slice) == 0 {
+		return Span{}
+	}
+	return p.slice[0].Span.join(p.slice[len(p.slice)-1].Span)
 }
 
 func (p *Parser) currentToken() (string, error) {
 	if p.index >= len(p.slice) {
-		return "", fmt.Errorf("unexpected end of slice %v", p.slice)
+		return "", &unexpectedEndErr{p.getSlice()}
 	}
-	return p.slice[p.index], nil
+	return p.slice[p.index].Value, nil
 }
 
 func (it *Parser) testAndSkip(s string) error {
 	if it.index >= len(it.slice) {
-		return fmt.Errorf("unexpected end of slice %v", it.slice)
+		return &unexpectedEndErr{it.getSlice()}
 	}
-	if it.slice[it.index] == s {
+	if it.slice[it.index].Value == s {
 		it.index++
 		return nil
 	}
-	return fmt.Errorf("expected '%s', but got '%s'", s, it.slice[it.index])
+	return fmt.Errorf("expected '%s', but got '%s'", s, it.slice[it.index].Value)
 }
 
 func (it *Parser) next() (string, error) {
 	if it.index >= len(it.slice) {
-		return "", fmt.Errorf("unexpected end of slice %v", it.slice)
+		return "", &unexpectedEndErr{it.getSlice()}
 	}
 	it.index++
-	return it.slice[it.index-1], nil
+	return it.slice[it.index-1].Value, nil
 }
 
 func (it *Parser) is(s string) bool {
-	return it.index < len(it.slice) && it.slice[it.index] == s
+	return it.index < len(it.slice) && it.slice[it.index].Value == s
 }
 
 func (it *Parser) isAndSkip(expected string) bool {
-	if it.index < len(it.slice) && it.slice[it.index] == expected {
+	if it.index < len(it.slice) && it.slice[it.index].Value == expected {
 		it.index++
 		return true
 	} else {
@@ -89,7 +128,7 @@ func (it *Parser) ExceedEnd() bool {
 
 func (it *Parser) skip(expected ...string) error {
 	if it.index >= len(it.slice) {
-		return fmt.Errorf("unexpected end of slice %v", it.slice)
+		return &unexpectedEndErr{it.getSlice()}
 	}
 
 	if len(expected) == 0 {
@@ -97,10 +136,10 @@ func (it *Parser) skip(expected ...string) error {
 		return nil
 	}
 
-	if it.slice[it.index] == expected[0] {
+	if it.slice[it.index].Value == expected[0] {
 		it.index++
 	} else {
-		return fmt.Errorf("expected '%s', but got '%s'", expected[0], it.slice[it.index])
+		return fmt.Errorf("expected '%s', but got '%s'", expected[0], it.slice[it.index].Value)
 	}
 
 	return nil
@@ -111,7 +150,7 @@ func (it *Parser) curTokenBeginWithNumber() bool {
 		return false
 	}
 
-	if it.slice[it.index][0] >= '0' && it.slice[it.index][0] <= '9' {
+	if it.slice[it.index].Value[0] >= '0' && it.slice[it.index].Value[0] <= '9' {
 		return true
 	} else {
 		return false
@@ -127,20 +166,43 @@ func (it *Parser) parseGivenWordsThenExceedEnd(words *[]string) error {
 	if it.ExceedEnd() {
 		return nil
 	} else {
-		return fmt.Errorf("expected %v, but got %s", *words, it.slice[it.index])
+		return fmt.Errorf("expected %v, but got %s", *words, it.slice[it.index].Value)
 	}
 }
 
+type unexpectedEndErr struct {
+	tokens []string
+}
+
+func (e *unexpectedEndErr) Error() string {
+	return fmt.Sprintf("unexpected end of slice %v", e.tokens)
+}
+
 type parserErr struct {
 	previous error
 	parser   *Parser
 }
 
+// isPositionedErr reports whether the message of err starts with the position where it is found.
+// Only the innermost error of nested parser errors is positioned, as it is the most precise.
+func isPositionedErr(err error) bool {
+	switch err := err.(type) {
+	case *parserErr, *parseStmtErr:
+		return true
+	case *Diagnostic:
+		return err.Span.IsValid()
+	}
+	return false
+}
+
 func (e *parserErr) Error() string {
+	if isPositionedErr(e.previous) {
+		return e.previous.Error()
+	}
 	curTok, err := e.parser.currentToken()
 	if err != nil {
-		return fmt.Sprintf("error at %s, column %d: %s", e.parser.String(), e.parser.getIndex(), e.previous.Error())
+		return fmt.Sprintf("%s: error at %s: %s", e.parser.currentSpan(), e.parser.String(), e.previous.Error())
 	} else {
-		return fmt.Sprintf("error at %s, column %d, at '%s': %s", e.parser.String(), e.parser.getIndex(), curTok, e.previous.Error())
+		return fmt.Sprintf("%s: error at %s, at '%s': %s", e.parser.currentSpan(), e.parser.String(), curTok, e.previous.Error())
 	}
 }
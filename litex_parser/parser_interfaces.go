@@ -2,6 +2,8 @@ package litexparser
 
 type Stmt interface {
 	stmt()
+	GetSpan() Span
+	String() string
 }
 
 func (stmt *DefVarStmt) stmt()              {}
@@ -23,12 +25,15 @@ func (s *ClaimProveByContradictStmt) stmt() {}
 func (s *AxiomStmt) stmt()                  {}
 func (s *ThmStmt) stmt()                    {}
 func (s *IfFactStmt) stmt()                 {}
+func (s *DefNotationStmt) stmt()            {}
 
 // func (s *InlineForallStmt) stmt()           {}
 
 type FactStmt interface {
 	factStmt()
 	stmt()
+	GetSpan() Span
+	String() string
 }
 
 func (l *BlockForallStmt) factStmt()  {}
@@ -45,12 +50,15 @@ type SpecFactParams struct {
 
 type SpecFactStmt interface {
 	notFactStmtSetT(b bool)
+	setSpan(span Span)
 	factStmt()
 	stmt()
+	GetSpan() Span
+	String() string
 	GetTypeParamsAndParams() *SpecFactParams
 }
 
-func (r *RelationFactStmt) notFactStmtSetT(b bool) { r.isTrue = b }
+func (r *RelationFactStmt) notFactStmtSetT(b bool) { r.IsTrue = b }
 func (f *FuncFactStmt) notFactStmtSetT(b bool)     { f.IsTrue = b }
 func (f *RelationFactStmt) GetTypeParamsAndParams() *SpecFactParams {
 	panic("TODO: Implement type specific operator overloading first")
@@ -59,8 +67,10 @@ func (f *FuncFactStmt) GetTypeParamsAndParams() *SpecFactParams {
 	return &SpecFactParams{[]TypeVarStr{}, []Fc{}}
 }
 
-type fcType interface {
+// FcType is the type of a parameter: FcVarType, *FcFnType, *FcPropType or an undefined type.
+type FcType interface {
 	fcType()
+	String() string
 }
 
 func (f FcVarType) fcType()          {}
@@ -70,14 +80,17 @@ func (f *UndefinedFnType) fcType()   {}
 func (f *UndefinedVarType) fcType()  {}
 func (f *UndefinedPropType) fcType() {}
 
-type fcDecl interface {
+// FcDecl declares a variable, a function or a proposition.
+type FcDecl interface {
 	fcDecl()
+	String() string
 }
 
 func (f *FcVarDecl) fcDecl() {}
 func (f *FcFnDecl) fcDecl()  {}
 func (f *PropDecl) fcDecl()  {}
 
+// FcVarTypeValue is FcVarTypeStrValue or *FcVarTypeFuncValue.
 type FcVarTypeValue interface {
 	fcVarTypeValue()
 }
@@ -88,6 +101,8 @@ func (f *FcVarTypeFuncValue) fcVarTypeValue() {}
 type ClaimStmt interface {
 	claimStmt()
 	stmt()
+	GetSpan() Span
+	String() string
 }
 
 func (s *ClaimProveStmt) claimStmt()             {}
@@ -98,6 +113,8 @@ func (s *ClaimProveByContradictStmt) claimStmt() {}
 type DefPropExistDeclStmt interface {
 	defPropExistDeclStmt()
 	stmt()
+	GetSpan() Span
+	String() string
 }
 
 func (s *DefExistStmt) defPropExistDeclStmt() {}
@@ -107,6 +124,8 @@ type InlineFactStmt interface {
 	inlineFactStmt()
 	factStmt()
 	stmt()
+	GetSpan() Span
+	String() string
 }
 
 func (r *RelationFactStmt) inlineFactStmt() {}
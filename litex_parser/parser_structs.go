@@ -1,143 +1,205 @@
 package litexparser
 
+// The AST of Litex. Every statement is a pointer to one of the *Stmt structs below and implements
+// Stmt; facts implement FactStmt too. Spans are set by the parser and are zero for nodes built by
+// hand. Walk and Inspect visit a tree; MarshalNode and TopStmt encode it to JSON.
+
+// TopStmt is a statement at the top level of a source file.
 type TopStmt struct {
 	Stmt  Stmt
-	IsPub bool
+	IsPub bool   // the statement is exported by pub
+	File  string // empty if the source code is not read from a file
+	Line  int    // line where the statement starts, starting from 1
+	Index int    // index of the statement among the top-level statements of its source code
 }
 
+// DefVarStmt declares a variable: var x G, followed by facts known about it.
 type DefVarStmt struct {
 	Decl  FcVarDecl
 	Facts []FactStmt
+	Span  Span
 }
 
 // if concept and type has more conceptTypes, use know impl
 
+// DefConceptStmt declares a concept: concept var T C impl Parent, with the members each type of
+// the concept must have.
 type DefConceptStmt struct {
-	decl           fcDecl
-	conceptName    TypeConceptStr
-	typeVarMember  []FcVarDecl
-	typeFnMember   []FcFnDecl
-	typePropMember []PropDecl
-	varMember      []FcVarDecl
-	fnMember       []FcFnDecl
-	propMember     []PropDecl
-	thenFacts      []FactStmt
-}
-
+	Decl           FcDecl
+	ConceptName    TypeConceptStr // the concept it implements, empty if none
+	TypeVarMember  []FcVarDecl    // members of the type itself, declared under type_member
+	TypeFnMember   []FcFnDecl
+	TypePropMember []PropDecl
+	VarMember      []FcVarDecl // members of each object of the type, declared under member
+	FnMember       []FcFnDecl
+	PropMember     []PropDecl
+	ThenFacts      []FactStmt
+	Doc            string // the doc comment before the declaration, without its comment markers
+	Span           Span
+}
+
+// DefTypeStmt declares a type: type impl C var T, with its members.
 type DefTypeStmt struct {
-	decl fcDecl
-	// implType can be concept, or type, because a new type can either
+	Decl FcDecl
+	// ImplType can be concept, or type, because a new type can either
 	// implement a concept or just be a subset of a type
-	implType       NamedFcType
-	typeVarMember  []FcVarDecl
-	typeFnMember   []FcFnDecl
-	typePropMember []PropDecl
-	varMember      []FcVarDecl
-	fnMember       []FcFnDecl
-	propMember     []PropDecl
-	thenFacts      []FactStmt
-}
-
+	ImplType       NamedFcType
+	TypeVarMember  []FcVarDecl // members of the type itself, declared under type_member
+	TypeFnMember   []FcFnDecl
+	TypePropMember []PropDecl
+	VarMember      []FcVarDecl // members of each object of the type, declared under member
+	FnMember       []FcFnDecl
+	PropMember     []PropDecl
+	ThenFacts      []FactStmt
+	Doc            string // the doc comment before the declaration, without its comment markers
+	Span           Span
+}
+
+// DefPropStmt declares a proposition: prop p(x G), with the facts that hold when it is true.
 type DefPropStmt struct {
-	decl      PropDecl
-	ifFacts   []FactStmt
-	thenFacts []FactStmt
+	Decl      PropDecl
+	IfFacts   []FactStmt // the cond block
+	ThenFacts []FactStmt
+	Doc       string // the doc comment before the declaration, without its comment markers
+	Span      Span
 }
 
+// DefFnStmt declares a function: fn f(x G) G, with the facts its return value satisfies.
 type DefFnStmt struct {
-	name string
-	tp   FcFnType
+	Name string
+	Tp   FcFnType
 	// decl      FcFnDecl
-	ifFacts   []FactStmt
-	thenFacts []FactStmt
+	IfFacts   []FactStmt // the cond block
+	ThenFacts []FactStmt
+	Doc       string // the doc comment before the declaration, without its comment markers
+	Span      Span
 }
 
+// BlockForallStmt is the fact forall [T C] x T: cond: ... then: ...
 type BlockForallStmt struct {
-	typeParams []TypeConceptPair
-	varParams  []StrTypePair
-	cond       []FactStmt
-	then       []SpecFactStmt
+	TypeParams []TypeConceptPair
+	VarParams  []StrTypePair
+	Cond       []FactStmt
+	Then       []SpecFactStmt
+	Span       Span
 }
 
+// FuncFactStmt is the fact $p(x), or not $p(x) if IsTrue is false.
 type FuncFactStmt struct {
 	IsTrue bool
-	Fc     Fc
+	Fc     Fc // the proposition applied to its parameters
+	Span   Span
 }
 
 // 1 = 2 -1 = 1 * 1, vars = [1, 2 -1, 1 * 1], opt = "="
 type RelationFactStmt struct {
-	isTrue bool
-	vars   []Fc
-	opt    Fc
+	IsTrue bool
+	Vars   []Fc
+	Opt    Fc
+	Span   Span
 }
 
+// ClaimProveByContradictStmt is claim: facts prove_by_contradiction: proof.
 type ClaimProveByContradictStmt struct {
-	toCheck []FactStmt
-	proof   []Stmt
+	ToCheck []FactStmt
+	Proof   []Stmt
+	Span    Span
 }
 
+// ClaimProveStmt is claim: facts prove: proof, or a prove block alone if ToCheck is empty.
 type ClaimProveStmt struct {
-	toCheck []FactStmt
-	proof   []Stmt
+	ToCheck []FactStmt
+	Proof   []Stmt
+	Span    Span
 }
 
+// DefAliasStmt is alias previous new.
 type DefAliasStmt struct {
 	PreviousName string
 	NewName      string
+	Span         Span
 }
 
+// KnowStmt takes facts as true without proof.
 type KnowStmt struct {
-	facts []FactStmt
+	Facts []FactStmt
+	Span  Span
 }
 
+// DefExistStmt declares an existential proposition: exist e(x G), with the members it provides.
 type DefExistStmt struct {
-	decl      PropDecl
-	ifFacts   []FactStmt
-	member    []fcDecl
-	thenFacts []FactStmt
+	Decl      PropDecl
+	IfFacts   []FactStmt // the cond block
+	Member    []FcDecl
+	ThenFacts []FactStmt
+	Span      Span
 }
 
+// HaveStmt introduces the members of an existential proposition which holds.
 type HaveStmt struct {
-	propStmt SpecFactStmt
-	member   []string
+	PropStmt SpecFactStmt
+	Member   []string
+	Span     Span
 }
 
+// DefMemberStmt declares a member of objects of a type: member [T C](x T) var m G.
 type DefMemberStmt struct {
-	typeConcept TypeConceptPair
-	varType     StrTypePair
-	member      fcDecl
-	facts       []FactStmt
+	TypeConcept TypeConceptPair
+	VarType     StrTypePair
+	Member      FcDecl
+	Facts       []FactStmt
+	Span        Span
 }
 
+// DefTypeMemberStmt declares a member of a type: type_member [T C] fn g(x G) G.
 type DefTypeMemberStmt struct {
-	typeConcept TypeConceptPair
-	member      fcDecl
-	facts       []FactStmt
+	TypeConcept TypeConceptPair
+	Member      FcDecl
+	Facts       []FactStmt
+	Span        Span
 }
 
 // syntax sugar for defining propExist + claim forall true
 type AxiomStmt struct {
-	decl DefPropExistDeclStmt
+	Decl DefPropExistDeclStmt
+	Doc  string // the doc comment before the declaration, without its comment markers
+	Span Span
 }
 
 // syntax sugar for defining propExist + claim forall true
 type ThmStmt struct {
-	decl  DefPropExistDeclStmt
-	proof []Stmt
+	Decl  DefPropExistDeclStmt
+	Proof []Stmt
+	Doc   string // the doc comment before the declaration, without its comment markers
+	Span  Span
 }
 
 // TODO 需要写一下 什么类型的事实写成什么样
 type IfFactStmt struct {
-	condFacts []FactStmt
-	thenFacts []SpecFactStmt
+	CondFacts []FactStmt
+	ThenFacts []SpecFactStmt
+	Span      Span
+}
+
+// DefNotationStmt declares an operator symbol which stands for a fn or a prop, see NotationTable.
+type DefNotationStmt struct {
+	Symbol       string
+	IsPrefix     bool   // prefix notation, or infix if false
+	IsProp       bool   // Binding is a prop, or a fn if false
+	Binding      string // the fn or prop which the symbol stands for
+	PrecedenceOf string // the operator which an infix fn notation has the precedence of, empty for +
+	IsRightAssoc bool
+	Span         Span
 }
 
 /*
 Data structures below are not statement nodes.
 */
 
+// TypeConceptStr is the name of a concept.
 type TypeConceptStr string
 
+// FcVarDecl declares a variable and its type: var x G.
 type FcVarDecl struct {
 	VarTypePair FcVarDeclPair
 }
@@ -147,56 +209,70 @@ type FcVarDeclPair struct {
 	Tp  FcVarType
 }
 
+// FcFnDecl declares a function: fn f(x G) G.
 type FcFnDecl struct {
-	name string
-	tp   FcFnType
+	Name string
+	Tp   FcFnType
 }
 
+// PropDecl declares a proposition: prop p(x G).
 type PropDecl struct {
-	name string
-	tp   FcPropType
+	Name string
+	Tp   FcPropType
 }
 
+// TypeConceptPair is a type parameter and its concept, as in [T Group].
 type TypeConceptPair struct {
 	Var  TypeVarStr
 	Type TypeConceptStr
 }
 
+// TypeVarStr is the name of a type, or of a type parameter.
 type TypeVarStr string
 
 type TypedTypeVar struct {
-	value   TypeVarStr
-	concept TypeConceptStr
+	Value   TypeVarStr
+	Concept TypeConceptStr
 }
 
+// StrTypePair is a parameter and its type, as in (x G).
 type StrTypePair struct {
 	Var  string
-	Type fcType
+	Type FcType
 }
 
+// FcVarType is the type of a variable: a type name, or a type returned by a function, with the
+// package it comes from.
 type FcVarType struct {
-	PackageName string
+	PackageName string // empty for the current package
 	Value       FcVarTypeValue
 }
 
+// FcVarTypeStrValue is a type given by its name.
 type FcVarTypeStrValue string
+
+// FcVarTypeFuncValue is a type returned by a function: Name[TypeParams](VarParams).
 type FcVarTypeFuncValue struct {
 	Name       string
 	TypeParams []TypeVarStr
 	VarParams  []Fc
 }
 
+// FcFnType is the type of a function: fn[TypeParamsTypes](VarParamsTypes) RetType.
 type FcFnType struct {
-	typeParamsTypes []TypeConceptPair
-	varParamsTypes  []StrTypePair
-	retType         fcType
+	TypeParamsTypes []TypeConceptPair
+	VarParamsTypes  []StrTypePair
+	RetType         FcType
 }
 
+// FcPropType is the type of a proposition: prop[TypeParams](VarParams).
 type FcPropType struct {
-	typeParams []TypeConceptPair
-	varParams  []StrTypePair
+	TypeParams []TypeConceptPair
+	VarParams  []StrTypePair
 }
 
+// UndefinedFnType, UndefinedVarType and UndefinedPropType are the types ?fn, ?var and ?prop of
+// parameters whose exact type is not given. Each has a single instance.
 type UndefinedFnType struct{}
 
 var undefinedFnTypeInstance *UndefinedFnType = &UndefinedFnType{}
@@ -214,14 +290,16 @@ var VarType = Keywords["var"]
 var FnType = Keywords["fn"]
 var PropType = Keywords["prop"]
 
+// NamedFcType is a type or concept referred to by its name, as in type impl Real Nat.
 type NamedFcType struct {
-	typeNameArr []string // packageName.packageName.typeName
-	params      []Fc
+	TypeNameArr []string // packageName.packageName.typeName
+	Params      []Fc
 }
 
 type fcUndefinedType interface {
 	fcUndefinedType()
 	fcType()
+	String() string
 }
 
 func (f *UndefinedFnType) fcUndefinedType()   {}
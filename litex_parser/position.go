@@ -0,0 +1,134 @@
+package litexparser
+
+import "fmt"
+
+// Position is a place in source code. Line and Column start from 1; Column counts runes, so
+// a tab is one column.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Span is the source range from Start up to, but not including, End.
+// The zero Span means the position is unknown, e.g. for nodes built by hand instead of parsed.
+type Span struct {
+	File  string   `json:"file,omitempty"` // empty if the source code is not read from a file
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+func (s Span) IsValid() bool {
+	return s.Start.Line > 0
+}
+
+// String returns file:line:column of the start of the span.
+func (s Span) String() string {
+	file := s.File
+	if file == "" {
+		file = "<source>"
+	}
+	if !s.IsValid() {
+		return file
+	}
+	return fmt.Sprintf("%s:%s", file, s.Start)
+}
+
+// join returns the smallest span covering both s and other. Invalid spans are ignored.
+func (s Span) join(other Span) Span {
+	if !s.IsValid() {
+		return other
+	}
+	if !other.IsValid() {
+		return s
+	}
+	ret := s
+	if positionBefore(other.Start, ret.Start) {
+		ret.Start = other.Start
+	}
+	if positionBefore(ret.End, other.End) {
+		ret.End = other.End
+	}
+	return ret
+}
+
+func positionBefore(a, b Position) bool {
+	return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+}
+
+// Token is a word of source code together with where it is.
+type Token struct {
+	Value string
+	Kind  TokenKind
+	Span  Span
+
+	notation *notation // the notation which the token is the symbol of, if any
+	doc      string    // the doc comment of the statement which the token starts
+}
+
+func (t Token) String() string {
+	return t.Value
+}
+
+// Every statement node and Fc records the span of source code it is parsed from.
+
+func (s *DefVarStmt) GetSpan() Span                 { return s.Span }
+func (s *DefConceptStmt) GetSpan() Span             { return s.Span }
+func (s *DefTypeStmt) GetSpan() Span                { return s.Span }
+func (s *DefPropStmt) GetSpan() Span                { return s.Span }
+func (s *DefFnStmt) GetSpan() Span                  { return s.Span }
+func (s *BlockForallStmt) GetSpan() Span            { return s.Span }
+func (s *RelationFactStmt) GetSpan() Span           { return s.Span }
+func (s *FuncFactStmt) GetSpan() Span               { return s.Span }
+func (s *ClaimProveStmt) GetSpan() Span             { return s.Span }
+func (s *DefAliasStmt) GetSpan() Span               { return s.Span }
+func (s *KnowStmt) GetSpan() Span                   { return s.Span }
+func (s *DefExistStmt) GetSpan() Span               { return s.Span }
+func (s *HaveStmt) GetSpan() Span                   { return s.Span }
+func (s *DefMemberStmt) GetSpan() Span              { return s.Span }
+func (s *DefTypeMemberStmt) GetSpan() Span          { return s.Span }
+func (s *ClaimProveByContradictStmt) GetSpan() Span { return s.Span }
+func (s *AxiomStmt) GetSpan() Span                  { return s.Span }
+func (s *ThmStmt) GetSpan() Span                    { return s.Span }
+func (s *IfFactStmt) GetSpan() Span                 { return s.Span }
+func (s *DefNotationStmt) GetSpan() Span            { return s.Span }
+
+func (f FcStr) GetSpan() Span         { return f.Span }
+func (f *FcFnRetValue) GetSpan() Span { return f.Span }
+func (f *FcMemChain) GetSpan() Span   { return f.Span }
+func (f *FcLambdaFn) GetSpan() Span   { return f.Span }
+func (f *FcLambdaProp) GetSpan() Span { return f.Span }
+
+func (s *DefVarStmt) setSpan(span Span)                 { s.Span = span }
+func (s *DefConceptStmt) setSpan(span Span)             { s.Span = span }
+func (s *DefTypeStmt) setSpan(span Span)                { s.Span = span }
+func (s *DefPropStmt) setSpan(span Span)                { s.Span = span }
+func (s *DefFnStmt) setSpan(span Span)                  { s.Span = span }
+func (s *BlockForallStmt) setSpan(span Span)            { s.Span = span }
+func (s *RelationFactStmt) setSpan(span Span)           { s.Span = span }
+func (s *FuncFactStmt) setSpan(span Span)               { s.Span = span }
+func (s *ClaimProveStmt) setSpan(span Span)             { s.Span = span }
+func (s *DefAliasStmt) setSpan(span Span)               { s.Span = span }
+func (s *KnowStmt) setSpan(span Span)                   { s.Span = span }
+func (s *DefExistStmt) setSpan(span Span)               { s.Span = span }
+func (s *HaveStmt) setSpan(span Span)                   { s.Span = span }
+func (s *DefMemberStmt) setSpan(span Span)              { s.Span = span }
+func (s *DefTypeMemberStmt) setSpan(span Span)          { s.Span = span }
+func (s *ClaimProveByContradictStmt) setSpan(span Span) { s.Span = span }
+func (s *AxiomStmt) setSpan(span Span)                  { s.Span = span }
+func (s *ThmStmt) setSpan(span Span)                    { s.Span = span }
+func (s *IfFactStmt) setSpan(span Span)                 { s.Span = span }
+func (s *DefNotationStmt) setSpan(span Span)            { s.Span = span }
+
+// setStmtSpan records span on stmt unless a more precise span was recorded while parsing it.
+func setStmtSpan(stmt Stmt, span Span) {
+	if stmt == nil || stmt.GetSpan().IsValid() {
+		return
+	}
+	if s, ok := stmt.(interface{ setSpan(Span) }); ok {
+		s.setSpan(span)
+	}
+}
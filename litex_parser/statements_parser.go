@@ -2,6 +2,7 @@ package litexparser
 
 import (
 	"fmt"
+	"os"
 	"strings"
 )
 
@@ -10,58 +11,166 @@ type parseStmtErr struct {
 	stmt     TokenBlock
 }
 
+type trailingTokensErr struct{}
+
+func (e *trailingTokensErr) Error() string {
+	return "unexpected token after the end of statement"
+}
+
 func (e *parseStmtErr) Error() string {
+	if isPositionedErr(e.previous) {
+		return e.previous.Error()
+	}
 	curTok, err := e.stmt.Header.currentToken()
 	if err != nil {
-		return fmt.Sprintf("error at %s, column %d: %s", e.stmt.Header.String(), e.stmt.Header.getIndex(), e.previous.Error())
+		return fmt.Sprintf("%s: error at %s: %s", e.stmt.Header.currentSpan(), e.stmt.Header.String(), e.previous.Error())
 	} else {
-		return fmt.Sprintf("error at %s, column %d, at '%s': %s", e.stmt.Header.String(), e.stmt.Header.getIndex(), curTok, e.previous.Error())
+		return fmt.Sprintf("%s: error at %s, at '%s': %s", e.stmt.Header.currentSpan(), e.stmt.Header.String(), curTok, e.previous.Error())
 	}
 }
 
-func ParseSourceCode(code string) (*[]TopStmt, error) {
-	code = strings.ReplaceAll(code, "\t", "    ")
+// ParseErrors is every error found when parsing source code. The parser recovers at the boundary
+// of each top-level statement and of each statement in a prove block, so the statements which
+// parse are returned together with ParseErrors.
+type ParseErrors []error
 
-	slice, err := getTopLevelStmtSlice(code)
-	if err != nil {
-		return nil, err
+func (errs ParseErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
 	}
+	return strings.Join(messages, "\n")
+}
 
-	blocks := []TokenBlock{}
-	for _, strBlock := range slice.body {
-		block, err := TokenizeStmtBlock(&strBlock)
-		if err != nil {
-			return nil, err
-		}
-		blocks = append(blocks, *block)
+func (errs *ParseErrors) add(err error) {
+	if nested, ok := err.(ParseErrors); ok {
+		*errs = append(*errs, nested...)
+	} else {
+		*errs = append(*errs, err)
+	}
+}
+
+// Parse parses every top-level statement of code as opts tells, and adds code to files unless
+// files is nil. If some lines do not fit the layout of blocks or some statements fail to parse, the
+// error is ParseErrors, with the layout errors first, and the returned slice holds the statements
+// which parse; a statement whose prove block has errors is returned without the failing statements
+// of the proof.
+func Parse(code string, files *FileSet, opts ParseOptions) (*[]TopStmt, error) {
+	if files != nil {
+		files.add(opts.File, code)
+	}
+	if opts.Notations == nil {
+		opts.Notations = NewNotationTable()
+	}
+
+	slice, err := splitBlocks(code, opts.File, opts.Indent)
+	if slice == nil {
+		return nil, err
 	}
 
 	ret := []TopStmt{}
-	for _, block := range blocks {
-		cur, err := block.ParseTopLevelStmt()
-		if err != nil {
-			return nil, err
+	errs := ParseErrors{}
+	if err != nil {
+		errs.add(err)
+	}
+	for i, strBlock := range slice.body {
+		cur, curErrs := opts.parseTopLevelBlock(&strBlock, i, opts.Notations)
+		errs = append(errs, curErrs...)
+		if cur != nil {
+			ret = append(ret, *cur)
 		}
-		ret = append(ret, *cur)
-		fmt.Printf("%v\n", cur)
 	}
 
+	if len(errs) > 0 {
+		return &ret, errs
+	}
 	return &ret, nil
 }
 
+// ParseFile parses the file at filePath like Parse, with opts.File set to filePath if it is empty.
+func ParseFile(filePath string, files *FileSet, opts ParseOptions) (*[]TopStmt, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.File == "" {
+		opts.File = filePath
+	}
+	return Parse(string(content), files, opts)
+}
+
+// ParseSourceCode parses code with the default options of Parse.
+func ParseSourceCode(code string) (*[]TopStmt, error) {
+	return Parse(code, nil, ParseOptions{})
+}
+
+// ParseSourceFile parses the file like ParseSourceCode and records the file in every TopStmt.
+func ParseSourceFile(filePath string) (*[]TopStmt, error) {
+	return ParseFile(filePath, nil, ParseOptions{})
+}
+
+// parseTopLevelBlock parses b, the top-level block with index i, and declares the notation it may
+// declare in notations. The statement is nil if the block does not parse.
+func (opts *ParseOptions) parseTopLevelBlock(b *strBlock, i int, notations *NotationTable) (*TopStmt, ParseErrors) {
+	cur, errs := opts.parseTopLevelStmt(b, i, notations)
+	if opts.Trace != nil {
+		var err error
+		if len(errs) > 0 {
+			err = errs
+		}
+		opts.Trace(cur, err)
+	}
+	return cur, errs
+}
+
+func (opts *ParseOptions) parseTopLevelStmt(b *strBlock, i int, notations *NotationTable) (*TopStmt, ParseErrors) {
+	errs := ParseErrors{}
+	block, err := tokenizeStmtBlock(b, opts.File, notations)
+	if err != nil {
+		errs.add(err)
+		return nil, errs
+	}
+
+	cur, err := block.ParseTopLevelStmt()
+	if err != nil {
+		errs.add(err)
+		if cur == nil {
+			return nil, errs
+		}
+	}
+	if err := opts.checkFeatures(cur.Stmt); err != nil {
+		errs.add(err)
+		return nil, errs
+	}
+	if decl, ok := cur.Stmt.(*DefNotationStmt); ok {
+		if err := notations.Declare(decl, cur.IsPub); err != nil {
+			errs.add(&Diagnostic{Code: DiagNotationError, Severity: SeverityError, Message: err.Error(), Span: decl.Span})
+			return nil, errs
+		}
+	}
+	cur.File = opts.File
+	cur.Line = b.line
+	cur.Index = i
+	return cur, errs
+}
+
 func (stmt *TokenBlock) ParseTopLevelStmt() (*TopStmt, error) {
 	pub := false
-	if stmt.Header.is(BuiltinSyms["pub"]) {
+	if stmt.Header.is(Keywords["pub"]) {
 		stmt.Header.skip()
 		pub = true
 	}
 
 	ret, err := stmt.ParseStmt()
 	if err != nil {
+		if errs, ok := err.(ParseErrors); ok {
+			return &TopStmt{Stmt: ret, IsPub: pub}, errs
+		}
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
-	return &TopStmt{ret, pub}, nil
+	return &TopStmt{Stmt: ret, IsPub: pub}, nil
 }
 
 func (stmt *TokenBlock) ParseStmt() (Stmt, error) {
@@ -102,18 +211,28 @@ func (stmt *TokenBlock) ParseStmt() (Stmt, error) {
 		ret, err = stmt.parseAxiomStmt()
 	case Keywords["thm"]:
 		ret, err = stmt.parseThmStmt()
+	case Keywords["notation"]:
+		ret, err = stmt.parseDefNotationStmt()
 	default:
 		ret, err = stmt.parseFactStmt()
 	}
 
 	if err != nil {
+		// a statement whose prove block has errors is returned with ParseErrors
+		if errs, ok := err.(ParseErrors); ok {
+			setStmtSpan(ret, stmt.span())
+			setStmtDoc(ret, stmt.doc())
+			return ret, errs
+		}
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
 	if !stmt.Header.ExceedEnd() {
-		return nil, &parseStmtErr{err, *stmt}
+		return nil, &parseStmtErr{&trailingTokensErr{}, *stmt}
 	}
 
+	setStmtSpan(ret, stmt.span())
+	setStmtDoc(ret, stmt.doc())
 	return ret, nil
 }
 
@@ -222,7 +341,7 @@ func (stmt *TokenBlock) parseDefConceptStmt() (*DefConceptStmt, error) {
 	conceptName := TypeConceptStr(conceptNameStr)
 
 	if !stmt.Header.is(BuiltinSyms[":"]) {
-		return &DefConceptStmt{decl, (conceptName), []FcVarDecl{}, []FcFnDecl{}, []PropDecl{}, []FcVarDecl{}, []FcFnDecl{}, []PropDecl{}, []FactStmt{}}, nil
+		return &DefConceptStmt{decl, (conceptName), []FcVarDecl{}, []FcFnDecl{}, []PropDecl{}, []FcVarDecl{}, []FcFnDecl{}, []PropDecl{}, []FactStmt{}, "", stmt.span()}, nil
 	} else {
 		stmt.Header.next()
 	}
@@ -254,7 +373,7 @@ func (stmt *TokenBlock) parseDefConceptStmt() (*DefConceptStmt, error) {
 		}
 	}
 
-	return &DefConceptStmt{decl, (conceptName), *typeVarMember, *typeFnMember, *typePropMember, *varMember, *fnMember, *propMember, *thenFacts}, nil
+	return &DefConceptStmt{decl, (conceptName), *typeVarMember, *typeFnMember, *typePropMember, *varMember, *fnMember, *propMember, *thenFacts, "", stmt.span()}, nil
 
 }
 
@@ -282,7 +401,7 @@ func (stmt *TokenBlock) parseDefTypeStmt() (*DefTypeStmt, error) {
 
 		decl := FcVarDecl{FcVarDeclPair{"", FcVarType{"", FcVarTypeStrValue(typeName)}}}
 
-		return &DefTypeStmt{&decl, *implName, []FcVarDecl{}, []FcFnDecl{}, []PropDecl{}, []FcVarDecl{}, []FcFnDecl{}, []PropDecl{}, []FactStmt{}}, nil
+		return &DefTypeStmt{&decl, *implName, []FcVarDecl{}, []FcFnDecl{}, []PropDecl{}, []FcVarDecl{}, []FcFnDecl{}, []PropDecl{}, []FactStmt{}, "", stmt.span()}, nil
 	}
 
 	decl, err := stmt.parseFcDecl()
@@ -291,7 +410,7 @@ func (stmt *TokenBlock) parseDefTypeStmt() (*DefTypeStmt, error) {
 	}
 
 	if !stmt.Header.is(BuiltinSyms[":"]) {
-		return &DefTypeStmt{decl, *implName, []FcVarDecl{}, []FcFnDecl{}, []PropDecl{}, []FcVarDecl{}, []FcFnDecl{}, []PropDecl{}, []FactStmt{}}, nil
+		return &DefTypeStmt{decl, *implName, []FcVarDecl{}, []FcFnDecl{}, []PropDecl{}, []FcVarDecl{}, []FcFnDecl{}, []PropDecl{}, []FactStmt{}, "", stmt.span()}, nil
 	} else {
 		stmt.Header.next()
 	}
@@ -322,17 +441,26 @@ func (stmt *TokenBlock) parseDefTypeStmt() (*DefTypeStmt, error) {
 			}
 		}
 	}
-	return &DefTypeStmt{decl, *implName, *typeVarMember, *typeFnMember, *typePropMember, *varMember, *fnMember, *propMember, *thenFacts}, nil
+	return &DefTypeStmt{decl, *implName, *typeVarMember, *typeFnMember, *typePropMember, *varMember, *fnMember, *propMember, *thenFacts, "", stmt.span()}, nil
 }
 
 func (stmt *TokenBlock) parseFactStmt() (FactStmt, error) {
+	var ret FactStmt
+	var err error
 	if stmt.Header.is(Keywords["forall"]) {
-		return stmt.parseForallStmt()
+		ret, err = stmt.parseForallStmt()
 	} else if stmt.Header.is(Keywords["if"]) {
-		return stmt.parseIfStmt()
+		ret, err = stmt.parseIfStmt()
+	} else {
+		ret, err = stmt.parseInlineFactStmt()
 	}
 
-	return stmt.parseInlineFactStmt()
+	if err != nil {
+		return nil, err
+	}
+	setStmtSpan(ret, stmt.span())
+	setStmtDoc(ret, stmt.doc())
+	return ret, nil
 }
 
 func (stmt *TokenBlock) parseIfStmt() (FactStmt, error) {
@@ -354,6 +482,7 @@ func (stmt *TokenBlock) parseInlineFactStmt() (FactStmt, error) {
 }
 
 func (stmt *TokenBlock) parseInlineForallStmt() (*BlockForallStmt, error) {
+	start := stmt.Header.getIndex()
 	err := stmt.Header.skip(Keywords["forall"])
 	if err != nil {
 		return nil, &parseStmtErr{err, *stmt}
@@ -402,13 +531,14 @@ func (stmt *TokenBlock) parseInlineForallStmt() (*BlockForallStmt, error) {
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
-	return &BlockForallStmt{*typeParams, *varParams, condFacts, thenFacts}, nil
+	return &BlockForallStmt{*typeParams, *varParams, condFacts, thenFacts, stmt.Header.spanFrom(start)}, nil
 }
 
 func (stmt *TokenBlock) parseInstantiatedFactStmt() (SpecFactStmt, error) {
+	start := stmt.Header.getIndex()
 	isTrue := true
-	if stmt.Header.is(BuiltinSyms["not"]) {
-		err := stmt.Header.skip(BuiltinSyms["not"])
+	if stmt.Header.is(Keywords["not"]) {
+		err := stmt.Header.skip(Keywords["not"])
 		if err != nil {
 			return nil, &parseStmtErr{err, *stmt}
 		}
@@ -428,6 +558,7 @@ func (stmt *TokenBlock) parseInstantiatedFactStmt() (SpecFactStmt, error) {
 	}
 
 	ret.notFactStmtSetT(isTrue)
+	ret.setSpan(stmt.Header.spanFrom(start))
 	return ret, nil
 }
 
@@ -442,7 +573,7 @@ func (stmt *TokenBlock) parseFuncPropFactStmt() (*FuncFactStmt, error) {
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
-	return &FuncFactStmt{true, fc}, nil
+	return &FuncFactStmt{true, fc, stmt.span()}, nil
 }
 
 func (stmt *TokenBlock) parseBlockedForall() (FactStmt, error) {
@@ -482,13 +613,13 @@ func (stmt *TokenBlock) parseBlockedForall() (FactStmt, error) {
 			return nil, fmt.Errorf("expected 'then'")
 		}
 	} else {
-		thenFacts, err = stmt.parseInstantiatedFactsBlock()
+		thenFacts, err = stmt.parseBodyInstantiatedFacts()
 		if err != nil {
 			return nil, &parseStmtErr{err, *stmt}
 		}
 	}
 
-	return &BlockForallStmt{*typeParams, *varParams, *ifFacts, *thenFacts}, nil
+	return &BlockForallStmt{*typeParams, *varParams, *ifFacts, *thenFacts, stmt.span()}, nil
 }
 
 func (stmt *TokenBlock) parseForallStmt() (FactStmt, error) {
@@ -558,12 +689,16 @@ func (stmt *TokenBlock) parseForallCondFactsBlock() (*[]FactStmt, error) {
 // }
 
 func (stmt *TokenBlock) parseInstantiatedFactsBlock() (*[]SpecFactStmt, error) {
-	facts := &[]SpecFactStmt{}
 	stmt.Header.skip()
 	if err := stmt.Header.testAndSkip(BuiltinSyms[":"]); err != nil {
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
+	return stmt.parseBodyInstantiatedFacts()
+}
+
+func (stmt *TokenBlock) parseBodyInstantiatedFacts() (*[]SpecFactStmt, error) {
+	facts := &[]SpecFactStmt{}
 	for _, curStmt := range stmt.Body {
 		fact, err := curStmt.parseInstantiatedFactStmt()
 		if err != nil {
@@ -591,7 +726,7 @@ func (stmt *TokenBlock) parseDefPropStmt() (*DefPropStmt, error) {
 		}
 	}
 
-	return &DefPropStmt{*decl, *ifFacts, *thenFacts}, nil
+	return &DefPropStmt{*decl, *ifFacts, *thenFacts, "", stmt.span()}, nil
 }
 
 func (stmt *TokenBlock) parseBodyCondFactsThenFacts() (*[]FactStmt, *[]FactStmt, error) {
@@ -646,7 +781,7 @@ func (stmt *TokenBlock) parseDefFnStmt() (*DefFnStmt, error) {
 		}
 	}
 
-	return &DefFnStmt{decl.name, decl.tp, *ifFacts, *thenFacts}, nil
+	return &DefFnStmt{decl.Name, decl.Tp, *ifFacts, *thenFacts, "", stmt.span()}, nil
 }
 
 func (stmt *TokenBlock) parseDefVarStmt() (*DefVarStmt, error) {
@@ -667,7 +802,7 @@ func (stmt *TokenBlock) parseDefVarStmt() (*DefVarStmt, error) {
 		return nil, fmt.Errorf("expect ':' or end of block")
 	}
 
-	return &DefVarStmt{*decl, *ifFacts}, nil
+	return &DefVarStmt{*decl, *ifFacts, stmt.span()}, nil
 }
 
 func (stmt *TokenBlock) parseClaimStmt() (ClaimStmt, error) {
@@ -678,8 +813,11 @@ func (stmt *TokenBlock) parseClaimStmt() (ClaimStmt, error) {
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
+	if len(stmt.Body) == 0 {
+		return nil, &parseStmtErr{fmt.Errorf("expect a claim body ending with 'prove' or 'prove_by_contradiction'"), *stmt}
+	}
+
 	toCheck := &[]FactStmt{}
-	proof := &[]Stmt{}
 
 	for i := 0; i < len(stmt.Body)-1; i++ {
 		if !stmt.Header.is(Keywords["prove"]) && !stmt.Header.is(Keywords["prove_by_contradiction"]) {
@@ -704,27 +842,29 @@ func (stmt *TokenBlock) parseClaimStmt() (ClaimStmt, error) {
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
-	for _, block := range stmt.Body[len(stmt.Body)-1].Body {
-		curStmt, err := block.ParseStmt()
-		if err != nil {
-			return nil, &parseStmtErr{err, *stmt}
-		}
-		*proof = append(*proof, curStmt)
-	}
+	proof, errs := parseProofStmts(stmt.Body[len(stmt.Body)-1].Body)
 
+	var ret ClaimStmt
 	if isProve {
-		return &ClaimProveStmt{*toCheck, *proof}, nil
+		ret = &ClaimProveStmt{*toCheck, proof, stmt.span()}
 	} else {
-		return &ClaimProveByContradictStmt{*toCheck, *proof}, nil
+		ret = &ClaimProveByContradictStmt{*toCheck, proof, stmt.span()}
+	}
+	if len(errs) > 0 {
+		return ret, errs
 	}
+	return ret, nil
 }
 
 func (stmt *TokenBlock) parseProveClaimStmt() (*ClaimProveStmt, error) {
 	innerStmtArr, err := stmt.parseProveBlock()
 	if err != nil {
+		if errs, ok := err.(ParseErrors); ok {
+			return &ClaimProveStmt{[]FactStmt{}, *innerStmtArr, stmt.span()}, errs
+		}
 		return nil, &parseStmtErr{err, *stmt}
 	}
-	return &ClaimProveStmt{[]FactStmt{}, *innerStmtArr}, nil
+	return &ClaimProveStmt{[]FactStmt{}, *innerStmtArr, stmt.span()}, nil
 }
 
 func (stmt *TokenBlock) parseProveBlock() (*[]Stmt, error) {
@@ -733,15 +873,29 @@ func (stmt *TokenBlock) parseProveBlock() (*[]Stmt, error) {
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
-	innerStmtArr := []Stmt{}
-	for _, innerStmt := range stmt.Body {
-		curStmt, err := innerStmt.ParseStmt()
+	innerStmtArr, errs := parseProofStmts(stmt.Body)
+	if len(errs) > 0 {
+		return &innerStmtArr, errs
+	}
+	return &innerStmtArr, nil
+}
+
+// parseProofStmts parses every statement of a proof. A statement which fails to parse is left out
+// and parsing goes on, so that errors of later statements are reported too.
+func parseProofStmts(blocks []TokenBlock) ([]Stmt, ParseErrors) {
+	ret := []Stmt{}
+	errs := ParseErrors{}
+	for _, block := range blocks {
+		curStmt, err := block.ParseStmt()
 		if err != nil {
-			return nil, &parseStmtErr{err, *stmt}
+			errs.add(err)
+			if _, ok := err.(ParseErrors); !ok {
+				continue
+			}
 		}
-		innerStmtArr = append(innerStmtArr, curStmt)
+		ret = append(ret, curStmt)
 	}
-	return &innerStmtArr, nil
+	return ret, errs
 }
 
 func (stmt *TokenBlock) parseDefAliasStmt() (*DefAliasStmt, error) {
@@ -757,7 +911,7 @@ func (stmt *TokenBlock) parseDefAliasStmt() (*DefAliasStmt, error) {
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
-	return &DefAliasStmt{previous, newName}, nil
+	return &DefAliasStmt{previous, newName, stmt.span()}, nil
 }
 
 func (stmt *TokenBlock) parseKnowStmt() (*KnowStmt, error) {
@@ -770,7 +924,7 @@ func (stmt *TokenBlock) parseKnowStmt() (*KnowStmt, error) {
 			return nil, &parseStmtErr{err, *stmt}
 		}
 		facts = append(facts, fact) // 之所以不能用,让know后面同一行里能有很多很多事实，是因为forall-fact是会换行的
-		return &KnowStmt{facts}, nil
+		return &KnowStmt{facts, stmt.span()}, nil
 	}
 
 	if err := stmt.Header.testAndSkip(BuiltinSyms[":"]); err != nil {
@@ -782,7 +936,7 @@ func (stmt *TokenBlock) parseKnowStmt() (*KnowStmt, error) {
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
-	return &KnowStmt{*facts}, nil
+	return &KnowStmt{*facts, stmt.span()}, nil
 }
 
 func (stmt *TokenBlock) parseDefExistStmt() (*DefExistStmt, error) {
@@ -792,7 +946,7 @@ func (stmt *TokenBlock) parseDefExistStmt() (*DefExistStmt, error) {
 	}
 
 	ifFacts := &[]FactStmt{}
-	member := &[]fcDecl{}
+	member := &[]FcDecl{}
 	thenFacts := &[]FactStmt{}
 	if !stmt.Header.is(BuiltinSyms[":"]) {
 		return nil, fmt.Errorf("expected ':‘")
@@ -815,7 +969,7 @@ func (stmt *TokenBlock) parseDefExistStmt() (*DefExistStmt, error) {
 			}
 			continue
 		}
-		if curStmt.Header.is(Keywords["members"]) {
+		if curStmt.Header.is(Keywords["member"]) {
 			member, err = curStmt.parseFcDecls()
 			if err != nil {
 				return nil, &parseStmtErr{err, *stmt}
@@ -824,11 +978,11 @@ func (stmt *TokenBlock) parseDefExistStmt() (*DefExistStmt, error) {
 		}
 	}
 
-	return &DefExistStmt{*decl, *ifFacts, *member, *thenFacts}, nil
+	return &DefExistStmt{*decl, *ifFacts, *member, *thenFacts, stmt.span()}, nil
 }
 
-func (stmt *TokenBlock) parseFcDecls() (*[]fcDecl, error) {
-	ret := []fcDecl{}
+func (stmt *TokenBlock) parseFcDecls() (*[]FcDecl, error) {
+	ret := []FcDecl{}
 
 	for _, curStmt := range stmt.Body {
 		cur, err := curStmt.parseFcDecl()
@@ -841,7 +995,7 @@ func (stmt *TokenBlock) parseFcDecls() (*[]fcDecl, error) {
 	return &ret, nil
 }
 
-func (stmt *TokenBlock) parseFcDecl() (fcDecl, error) {
+func (stmt *TokenBlock) parseFcDecl() (FcDecl, error) {
 	if stmt.Header.is(Keywords["fn"]) {
 		return stmt.Header.parseFcFnDecl()
 	} else if stmt.Header.is(Keywords["var"]) {
@@ -860,8 +1014,8 @@ func (stmt *TokenBlock) parseHaveStmt() (*HaveStmt, error) {
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
-	if !stmt.Header.is(BuiltinSyms[":"]) {
-		return nil, fmt.Errorf("expected ':'")
+	if err := stmt.Header.testAndSkip(BuiltinSyms[":"]); err != nil {
+		return nil, &parseStmtErr{err, *stmt}
 	}
 
 	if len(stmt.Body) != 1 {
@@ -873,7 +1027,7 @@ func (stmt *TokenBlock) parseHaveStmt() (*HaveStmt, error) {
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
-	return &HaveStmt{propStmt, *members}, nil
+	return &HaveStmt{propStmt, *members, stmt.span()}, nil
 }
 
 func (stmt *TokenBlock) parseMemberStmt() (*DefMemberStmt, error) {
@@ -901,7 +1055,7 @@ func (stmt *TokenBlock) parseMemberStmt() (*DefMemberStmt, error) {
 
 	varType := (*varTypes)[0]
 
-	var decl fcDecl
+	var decl FcDecl
 
 	if stmt.Header.is(Keywords["var"]) {
 		decl, err = stmt.Header.parseVarDecl()
@@ -923,7 +1077,7 @@ func (stmt *TokenBlock) parseMemberStmt() (*DefMemberStmt, error) {
 	}
 
 	if stmt.Header.ExceedEnd() {
-		return &DefMemberStmt{typeConcept, varType, decl, []FactStmt{}}, nil
+		return &DefMemberStmt{typeConcept, varType, decl, []FactStmt{}, stmt.span()}, nil
 	}
 
 	if err := stmt.Header.testAndSkip(BuiltinSyms[":"]); err != nil {
@@ -935,7 +1089,7 @@ func (stmt *TokenBlock) parseMemberStmt() (*DefMemberStmt, error) {
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
-	return &DefMemberStmt{typeConcept, varType, decl, *facts}, nil
+	return &DefMemberStmt{typeConcept, varType, decl, *facts, stmt.span()}, nil
 }
 
 func (stmt *TokenBlock) parseTypeMemberStmt() (*DefTypeMemberStmt, error) {
@@ -952,7 +1106,7 @@ func (stmt *TokenBlock) parseTypeMemberStmt() (*DefTypeMemberStmt, error) {
 
 	typeConcept := (*typeConcepts)[0]
 
-	var decl fcDecl
+	var decl FcDecl
 
 	if stmt.Header.is(Keywords["var"]) {
 		decl, err = stmt.Header.parseVarDecl()
@@ -974,7 +1128,7 @@ func (stmt *TokenBlock) parseTypeMemberStmt() (*DefTypeMemberStmt, error) {
 	}
 
 	if stmt.Header.ExceedEnd() {
-		return &DefTypeMemberStmt{typeConcept, decl, []FactStmt{}}, nil
+		return &DefTypeMemberStmt{typeConcept, decl, []FactStmt{}, stmt.span()}, nil
 	}
 
 	if err := stmt.Header.testAndSkip(BuiltinSyms[":"]); err != nil {
@@ -986,11 +1140,13 @@ func (stmt *TokenBlock) parseTypeMemberStmt() (*DefTypeMemberStmt, error) {
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
-	return &DefTypeMemberStmt{typeConcept, decl, *facts}, nil
+	return &DefTypeMemberStmt{typeConcept, decl, *facts, stmt.span()}, nil
 }
 
 func (stmt *TokenBlock) parseRelationalFactStmt() (SpecFactStmt, error) {
-	fc, err := stmt.Header.ParseFc()
+	start := stmt.Header.getIndex()
+	prefix := stmt.Header.currentNotation()
+	fc, err := stmt.Header.parseRelationOperand()
 	if err != nil {
 		return nil, &parseStmtErr{err, *stmt}
 	}
@@ -999,16 +1155,31 @@ func (stmt *TokenBlock) parseRelationalFactStmt() (SpecFactStmt, error) {
 		return stmt.Header.parseIsExpr(fc)
 	}
 
+	// the fact written with the notation of a prop is the prop applied to its operands
+	if prefix != nil && prefix.decl.IsPrefix && prefix.decl.IsProp && stmt.Header.ExceedEnd() {
+		return &FuncFactStmt{true, fc, stmt.span()}, nil
+	}
+	if infix := stmt.Header.currentNotation(); infix != nil && !infix.decl.IsPrefix && infix.decl.IsProp {
+		symbol := stmt.Header.currentSpan()
+		stmt.Header.skip()
+		fc2, err := stmt.Header.parseRelationOperand()
+		if err != nil {
+			return nil, &parseStmtErr{err, *stmt}
+		}
+		return &FuncFactStmt{true, notationApplication(infix, symbol, []Fc{fc, fc2}, stmt.Header.spanFrom(start)), stmt.span()}, nil
+	}
+
+	optSpan := stmt.Header.currentSpan()
 	opt, err := stmt.Header.next()
 	if err != nil {
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
 	if !isBuiltinRelationalOperator(opt) {
-		return nil, &parseStmtErr{err, *stmt}
+		return nil, &parseStmtErr{fmt.Errorf("expected relational operator, but got '%s'", opt), *stmt}
 	}
 
-	fc2, err := stmt.Header.ParseFc()
+	fc2, err := stmt.Header.parseRelationOperand()
 	if err != nil {
 		return nil, &parseStmtErr{err, *stmt}
 	}
@@ -1016,14 +1187,14 @@ func (stmt *TokenBlock) parseRelationalFactStmt() (SpecFactStmt, error) {
 	vars := []Fc{fc, fc2}
 	for stmt.Header.is(opt) {
 		stmt.Header.skip()
-		fc, err := stmt.Header.ParseFc()
+		fc, err := stmt.Header.parseRelationOperand()
 		if err != nil {
 			return nil, &parseStmtErr{err, *stmt}
 		}
 		vars = append(vars, fc)
 	}
 
-	return &RelationFactStmt{true, vars, FcStr(opt)}, nil
+	return &RelationFactStmt{true, vars, FcStr{opt, optSpan}, stmt.span()}, nil
 }
 
 func (stmt *TokenBlock) parseAxiomStmt() (*AxiomStmt, error) {
@@ -1033,7 +1204,7 @@ func (stmt *TokenBlock) parseAxiomStmt() (*AxiomStmt, error) {
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
-	return &AxiomStmt{decl}, nil
+	return &AxiomStmt{decl, "", stmt.span()}, nil
 }
 
 func (stmt *TokenBlock) parseThmStmt() (*ThmStmt, error) {
@@ -1060,13 +1231,17 @@ func (stmt *TokenBlock) parseThmStmt() (*ThmStmt, error) {
 
 	facts, err := stmt.Body[1].parseProveBlock()
 	if err != nil {
+		if errs, ok := err.(ParseErrors); ok {
+			return &ThmStmt{decl, *facts, "", stmt.span()}, errs
+		}
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
-	return &ThmStmt{decl, *facts}, nil
+	return &ThmStmt{decl, *facts, "", stmt.span()}, nil
 }
 
 func (stmt *TokenBlock) parseInlineIfFactStmt() (*IfFactStmt, error) {
+	start := stmt.Header.getIndex()
 	err := stmt.Header.skip(Keywords["if"])
 	if err != nil {
 		return nil, &parseStmtErr{err, *stmt}
@@ -1109,7 +1284,7 @@ func (stmt *TokenBlock) parseInlineIfFactStmt() (*IfFactStmt, error) {
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
-	return &IfFactStmt{condFacts, thenFacts}, nil
+	return &IfFactStmt{condFacts, thenFacts, stmt.Header.spanFrom(start)}, nil
 }
 
 func (stmt *TokenBlock) parseBlockIfStmt() (*IfFactStmt, error) {
@@ -1129,7 +1304,7 @@ func (stmt *TokenBlock) parseBlockIfStmt() (*IfFactStmt, error) {
 	thenFacts := []SpecFactStmt{}
 
 	for i := 0; i < len(stmt.Body)-1; i++ {
-		fact, err := stmt.Body[i].parseInstantiatedFactStmt()
+		fact, err := stmt.Body[i].parseFactStmt()
 		if err != nil {
 			return nil, &parseStmtErr{err, *stmt}
 		}
@@ -1145,7 +1320,7 @@ func (stmt *TokenBlock) parseBlockIfStmt() (*IfFactStmt, error) {
 		return nil, &parseStmtErr{err, *stmt}
 	}
 
-	for i := len(stmt.Body[len(stmt.Body)-1].Body) - 1; i >= 0; i-- {
+	for i := 0; i < len(stmt.Body[len(stmt.Body)-1].Body); i++ {
 		fact, err := stmt.Body[len(stmt.Body)-1].Body[i].parseInstantiatedFactStmt()
 		if err != nil {
 			return nil, &parseStmtErr{err, *stmt}
@@ -1153,5 +1328,5 @@ func (stmt *TokenBlock) parseBlockIfStmt() (*IfFactStmt, error) {
 		thenFacts = append(thenFacts, fact)
 	}
 
-	return &IfFactStmt{condFacts, thenFacts}, nil
+	return &IfFactStmt{condFacts, thenFacts, stmt.span()}, nil
 }
@@ -0,0 +1,403 @@
+package litexparser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// String methods below print nodes as Litex source which parses back into the same node.
+// Facts which take more than one line are printed in block form, with 4 spaces per indentation level.
+
+const stringIndent = "    "
+
+func indentLines(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = stringIndent + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+func joinStrings[T fmt.Stringer](items []T, sep string) string {
+	strs := make([]string, len(items))
+	for i, item := range items {
+		strs[i] = item.String()
+	}
+	return strings.Join(strs, sep)
+}
+
+func notPrefix(isTrue bool) string {
+	if isTrue {
+		return ""
+	}
+	return Keywords["not"] + " "
+}
+
+func (f *FuncFactStmt) String() string {
+	return notPrefix(f.IsTrue) + BuiltinSyms["$"] + f.Fc.String()
+}
+
+func (f *RelationFactStmt) String() string {
+	vars := make([]string, len(f.Vars))
+	for i, v := range f.Vars {
+		// operators binding looser than comparisons would be taken as the relation
+		vars[i] = fcOperandString(v, precComparison, true)
+	}
+	return notPrefix(f.IsTrue) + strings.Join(vars, fmt.Sprintf(" %s ", f.Opt))
+}
+
+func (l *BlockForallStmt) String() string {
+	header := Keywords["forall"]
+	if len(l.TypeParams) > 0 {
+		header += " " + bracketedTypeConceptPairs(l.TypeParams)
+	}
+	if len(l.VarParams) > 0 {
+		header += " " + joinStrings(l.VarParams, ", ")
+	}
+	header += BuiltinSyms[":"]
+
+	if len(l.Cond) == 0 {
+		return header + "\n" + indentLines(joinStrings(l.Then, "\n"))
+	}
+
+	cond := Keywords["cond"] + BuiltinSyms[":"] + "\n" + indentLines(joinStrings(l.Cond, "\n"))
+	then := Keywords["then"] + BuiltinSyms[":"] + "\n" + indentLines(joinStrings(l.Then, "\n"))
+	return header + "\n" + indentLines(cond) + "\n" + indentLines(then)
+}
+
+func (p *IfFactStmt) String() string {
+	header := Keywords["if"] + BuiltinSyms[":"]
+	then := Keywords["then"] + BuiltinSyms[":"] + "\n" + indentLines(joinStrings(p.ThenFacts, "\n"))
+	if len(p.CondFacts) == 0 {
+		return header + "\n" + indentLines(then)
+	}
+	return header + "\n" + indentLines(joinStrings(p.CondFacts, "\n")) + "\n" + indentLines(then)
+}
+
+func (p TypeConceptPair) String() string {
+	return fmt.Sprintf("%s %s", p.Var, p.Type)
+}
+
+func bracketedTypeConceptPairs(pairs []TypeConceptPair) string {
+	return BuiltinSyms["["] + joinStrings(pairs, ", ") + BuiltinSyms["]"]
+}
+
+func bracedStrTypePairs(pairs []StrTypePair) string {
+	return BuiltinSyms["("] + joinStrings(pairs, ", ") + BuiltinSyms[")"]
+}
+
+// [G Group](x G) is omitted part by part when the part is empty
+func typeConceptPairsAndStrTypePairs(typeParams []TypeConceptPair, varParams []StrTypePair) string {
+	ret := ""
+	if len(typeParams) > 0 {
+		ret += bracketedTypeConceptPairs(typeParams)
+	}
+	if len(varParams) > 0 {
+		ret += bracedStrTypePairs(varParams)
+	}
+	return ret
+}
+
+func (p StrTypePair) String() string {
+	return fmt.Sprintf("%s %s", p.Var, p.Type)
+}
+
+func (f FcVarType) String() string {
+	ret := ""
+	if f.PackageName != "" {
+		ret += f.PackageName + BuiltinSyms["::"]
+	}
+
+	switch value := f.Value.(type) {
+	case FcVarTypeStrValue:
+		ret += string(value)
+	case *FcVarTypeFuncValue:
+		ret += value.Name
+		if len(value.TypeParams) > 0 {
+			ret += BuiltinSyms["["] + joinStrings(value.TypeParams, ", ") + BuiltinSyms["]"]
+		}
+		if len(value.VarParams) > 0 {
+			ret += BuiltinSyms["("] + joinStrings(value.VarParams, ", ") + BuiltinSyms[")"]
+		}
+	}
+
+	return ret
+}
+
+func (f TypeVarStr) String() string {
+	return string(f)
+}
+
+func (f *FcFnType) String() string {
+	return fmt.Sprintf("%s%s %s", Keywords["fn"], typeConceptPairsAndStrTypePairs(f.TypeParamsTypes, f.VarParamsTypes), f.RetType)
+}
+
+func (f *FcPropType) String() string {
+	return Keywords["prop"] + typeConceptPairsAndStrTypePairs(f.TypeParams, f.VarParams)
+}
+
+func (f *UndefinedFnType) String() string   { return BuiltinSyms["?"] + Keywords["fn"] }
+func (f *UndefinedVarType) String() string  { return BuiltinSyms["?"] + Keywords["var"] }
+func (f *UndefinedPropType) String() string { return BuiltinSyms["?"] + Keywords["prop"] }
+
+func (f *PropDecl) String() string {
+	return fmt.Sprintf("%s %s%s", Keywords["prop"], f.Name, typeConceptPairsAndStrTypePairs(f.Tp.TypeParams, f.Tp.VarParams))
+}
+
+func (f *FcFnDecl) String() string {
+	return fmt.Sprintf("%s %s%s %s", Keywords["fn"], f.Name, typeConceptPairsAndStrTypePairs(f.Tp.TypeParamsTypes, f.Tp.VarParamsTypes), f.Tp.RetType)
+}
+
+func (f *FcVarDeclPair) String() string {
+	return fmt.Sprintf("%s %s %s", Keywords["var"], f.Var, f.Tp)
+}
+
+func (s *DefAliasStmt) String() string {
+	return fmt.Sprintf("%s %s %s", Keywords["alias"], s.PreviousName, s.NewName)
+}
+
+func (s *KnowStmt) String() string {
+	return Keywords["know"] + BuiltinSyms[":"] + "\n" + indentLines(joinStrings(s.Facts, "\n"))
+}
+
+// withBody prints a block statement: header, a colon and the indented body. A statement without
+// body is printed as its header only.
+func withBody(header string, body string) string {
+	if body == "" {
+		return header
+	}
+	return header + BuiltinSyms[":"] + "\n" + indentLines(body)
+}
+
+// optionalBlock prints a block which is left out when it has no body, e.g. the then block of a concept.
+func optionalBlock(header string, body string) string {
+	if body == "" {
+		return ""
+	}
+	return withBody(header, body)
+}
+
+func joinNonEmpty(strs ...string) string {
+	ret := []string{}
+	for _, s := range strs {
+		if s != "" {
+			ret = append(ret, s)
+		}
+	}
+	return strings.Join(ret, "\n")
+}
+
+func (s *TopStmt) String() string {
+	if !s.IsPub {
+		return s.Stmt.String()
+	}
+	// the doc comment goes before pub
+	if stmt, ok := s.Stmt.(interface{ withoutDoc() string }); ok {
+		return docComment(stmtDoc(s.Stmt)) + Keywords["pub"] + " " + stmt.withoutDoc()
+	}
+	return Keywords["pub"] + " " + s.Stmt.String()
+}
+
+func (f *FcVarDecl) String() string {
+	return f.VarTypePair.String()
+}
+
+func (t *NamedFcType) String() string {
+	ret := strings.Join(t.TypeNameArr, BuiltinSyms["."])
+	if len(t.Params) > 0 {
+		ret += BuiltinSyms["("] + joinStrings(t.Params, ", ") + BuiltinSyms[")"]
+	}
+	return ret
+}
+
+func (s *DefVarStmt) String() string {
+	return withBody(s.Decl.String(), joinStrings(s.Facts, "\n"))
+}
+
+// cond and then facts of props and fns: the then facts alone when there is no cond fact
+func condThenFactsString(condFacts []FactStmt, thenFacts []FactStmt) string {
+	if len(condFacts) == 0 {
+		return joinStrings(thenFacts, "\n")
+	}
+	return withBody(Keywords["cond"], joinStrings(condFacts, "\n")) + "\n" + withBody(Keywords["then"], joinStrings(thenFacts, "\n"))
+}
+
+func (s *DefPropStmt) String() string {
+	return docComment(s.Doc) + s.withoutDoc()
+}
+
+func (s *DefPropStmt) withoutDoc() string {
+	return withBody(s.Decl.String(), condThenFactsString(s.IfFacts, s.ThenFacts))
+}
+
+func (s *DefFnStmt) String() string {
+	return docComment(s.Doc) + s.withoutDoc()
+}
+
+func (s *DefFnStmt) withoutDoc() string {
+	decl := FcFnDecl{s.Name, s.Tp}
+	return withBody(decl.String(), condThenFactsString(s.IfFacts, s.ThenFacts))
+}
+
+func membersString(keyword string, vars []FcVarDecl, fns []FcFnDecl, props []PropDecl) string {
+	members := []string{}
+	for i := range vars {
+		members = append(members, vars[i].String())
+	}
+	for i := range fns {
+		members = append(members, fns[i].String())
+	}
+	for i := range props {
+		members = append(members, props[i].String())
+	}
+	return optionalBlock(Keywords[keyword], strings.Join(members, "\n"))
+}
+
+func (s *DefConceptStmt) String() string {
+	return docComment(s.Doc) + s.withoutDoc()
+}
+
+func (s *DefConceptStmt) withoutDoc() string {
+	header := Keywords["concept"] + " " + s.Decl.String()
+	if s.ConceptName != "" {
+		header += fmt.Sprintf(" %s %s", Keywords["impl"], s.ConceptName)
+	}
+	return withBody(header, joinNonEmpty(
+		membersString("type_member", s.TypeVarMember, s.TypeFnMember, s.TypePropMember),
+		membersString("member", s.VarMember, s.FnMember, s.PropMember),
+		optionalBlock(Keywords["then"], joinStrings(s.ThenFacts, "\n")),
+	))
+}
+
+func (s *DefTypeStmt) String() string {
+	return docComment(s.Doc) + s.withoutDoc()
+}
+
+func (s *DefTypeStmt) withoutDoc() string {
+	header := Keywords["type"]
+	if len(s.ImplType.TypeNameArr) > 0 {
+		header += fmt.Sprintf(" %s %s", Keywords["impl"], &s.ImplType)
+	}
+	// type T declares the type name alone
+	if decl, ok := s.Decl.(*FcVarDecl); ok && decl.VarTypePair.Var == "" {
+		return header + " " + decl.VarTypePair.Tp.String()
+	}
+	return withBody(header+" "+s.Decl.String(), joinNonEmpty(
+		membersString("type_member", s.TypeVarMember, s.TypeFnMember, s.TypePropMember),
+		membersString("member", s.VarMember, s.FnMember, s.PropMember),
+		optionalBlock(Keywords["then"], joinStrings(s.ThenFacts, "\n")),
+	))
+}
+
+func (s *ClaimProveStmt) String() string {
+	prove := withBody(Keywords["prove"], joinStrings(s.Proof, "\n"))
+	if len(s.ToCheck) == 0 {
+		return prove
+	}
+	return withBody(Keywords["claim"], joinStrings(s.ToCheck, "\n")+"\n"+prove)
+}
+
+func (s *ClaimProveByContradictStmt) String() string {
+	prove := withBody(Keywords["prove_by_contradiction"], joinStrings(s.Proof, "\n"))
+	return withBody(Keywords["claim"], joinNonEmpty(joinStrings(s.ToCheck, "\n"), prove))
+}
+
+func (s *DefExistStmt) String() string {
+	header := fmt.Sprintf("%s %s%s", Keywords["exist"], s.Decl.Name, typeConceptPairsAndStrTypePairs(s.Decl.Tp.TypeParams, s.Decl.Tp.VarParams))
+	return withBody(header, joinNonEmpty(
+		optionalBlock(Keywords["cond"], joinStrings(s.IfFacts, "\n")),
+		optionalBlock(Keywords["then"], joinStrings(s.ThenFacts, "\n")),
+		optionalBlock(Keywords["member"], joinStrings(s.Member, "\n")),
+	))
+}
+
+func (s *HaveStmt) String() string {
+	return withBody(Keywords["have"]+" "+s.PropStmt.String(), strings.Join(s.Member, ", "))
+}
+
+func (s *DefMemberStmt) String() string {
+	header := fmt.Sprintf("%s %s%s %s", Keywords["member"], bracketedTypeConceptPairs([]TypeConceptPair{s.TypeConcept}), bracedStrTypePairs([]StrTypePair{s.VarType}), s.Member)
+	return withBody(header, joinStrings(s.Facts, "\n"))
+}
+
+func (s *DefTypeMemberStmt) String() string {
+	header := fmt.Sprintf("%s %s %s", Keywords["type_member"], bracketedTypeConceptPairs([]TypeConceptPair{s.TypeConcept}), s.Member)
+	return withBody(header, joinStrings(s.Facts, "\n"))
+}
+
+func (s *AxiomStmt) String() string {
+	return docComment(s.Doc) + s.withoutDoc()
+}
+
+func (s *AxiomStmt) withoutDoc() string {
+	return Keywords["axiom"] + " " + s.Decl.String()
+}
+
+func (s *ThmStmt) String() string {
+	return docComment(s.Doc) + s.withoutDoc()
+}
+
+func (s *ThmStmt) withoutDoc() string {
+	return withBody(Keywords["thm"], s.Decl.String()+"\n"+withBody(Keywords["prove"], joinStrings(s.Proof, "\n")))
+}
+
+func (s *DefNotationStmt) String() string {
+	kind, binding := "infix", Keywords["fn"]
+	if s.IsPrefix {
+		kind = "prefix"
+	}
+	if s.IsProp {
+		binding = Keywords["prop"]
+	}
+	ret := fmt.Sprintf("%s %s %s %s %s", Keywords["notation"], kind, s.Symbol, binding, s.Binding)
+	if s.PrecedenceOf != "" {
+		ret += " precedence " + s.PrecedenceOf
+	}
+	if s.IsRightAssoc {
+		ret += " right"
+	}
+	return ret
+}
+
+// FormatTopStmts prints top-level statements as canonical Litex source, one statement per line or
+// block. Operators written as member function calls are printed in operator form, as NormalizeFact
+// gives them, with the types declared by stmts as the type names which may qualify the calls.
+func FormatTopStmts(stmts []TopStmt) string {
+	types := map[string]bool{}
+	for _, stmt := range stmts {
+		if defType, ok := stmt.Stmt.(*DefTypeStmt); ok {
+			if decl, ok := defType.Decl.(*FcVarDecl); ok {
+				types[decl.VarTypePair.Tp.String()] = true
+			}
+		}
+	}
+	n := normalizer{func(name string) bool { return types[name] }}
+
+	ret := ""
+	for _, stmt := range stmts {
+		stmt.Stmt = n.stmt(stmt.Stmt)
+		ret += stmt.String() + "\n"
+	}
+	return ret
+}
+
+// FormatSourceCode is the fmt mode of Litex: it parses code and prints it in canonical form.
+// Formatting is idempotent and the formatted code parses into the same statements, up to
+// normalization. Only doc comments of declarations are part of the AST, so code with any other
+// comment is not formatted: the error is a Diagnostic at the first such comment.
+func FormatSourceCode(code string) (string, error) {
+	docs, plain := scanComments(code)
+	if plain != nil {
+		return "", &Diagnostic{Code: DiagDroppedComment, Severity: SeverityError, Message: "formatting would drop this comment, which is not a doc comment", Span: *plain}
+	}
+
+	stmts, err := ParseSourceCode(code)
+	if err != nil {
+		return "", err
+	}
+	ret := FormatTopStmts(*stmts)
+	if kept, _ := scanComments(ret); len(kept) != len(docs) {
+		return "", &Diagnostic{Code: DiagDroppedComment, Severity: SeverityError, Message: "formatting would drop doc comments which are not before a declaration", Span: docs[0]}
+	}
+	return ret, nil
+}
@@ -2,84 +2,252 @@ package litexparser
 
 import (
 	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-// 提取出的 nextToken 函数，用于识别下一个 token
-func nextToken(inputString string, start int) (string, int, error) {
-	// 如果下两个字符是 //，跳过直到结束
-	if start+1 < len(inputString) && inputString[start:start+2] == "//" {
-		return "", len(inputString), nil
+// Source code is tokenized rune by rune in a single pass. A token is a keyword or a builtin symbol,
+// a declared notation symbol, a Unicode alias, an identifier or a number, or a single rune of any
+// other symbol such as ∘. Each token records its TokenKind and its span. Identifiers consist of
+// Unicode letters, marks, digits and _, so Greek and CJK names are identifiers. Aliases are
+// replaced by what they stand for, with the span of the alias as written.
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\r'
+}
+
+// isIdentifierRune reports whether r can be part of an identifier or a number. ASCII punctuation
+// is made of builtin symbols, and other runes which are neither letters nor digits are symbols.
+func isIdentifierRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) || unicode.IsNumber(r)
+}
+
+// TokenKind tells what a token is.
+type TokenKind uint8
+
+const (
+	TokenIdentifier TokenKind = iota // a name
+	TokenNumber                      // a word starting with a digit
+	TokenKeyword                     // a keyword, or an alias of a keyword such as ∀
+	TokenSymbol                      // a builtin symbol, a notation symbol or any other single rune
+)
+
+func (k TokenKind) String() string {
+	switch k {
+	case TokenIdentifier:
+		return "identifier"
+	case TokenNumber:
+		return "number"
+	case TokenKeyword:
+		return "keyword"
+	case TokenSymbol:
+		return "symbol"
 	}
-	// 如果下两个字符是 /*，报错
-	if start+1 < len(inputString) && inputString[start:start+2] == "/*" {
-		return "", 0, fmt.Errorf("invalid syntax: nested comment block")
+	return fmt.Sprintf("TokenKind(%d)", k)
+}
+
+// symbolTrie is a trie of symbols over their bytes. Finding the longest symbol at a position
+// reads at most as many bytes as the longest symbol has, so a line is tokenized in time linear
+// in its length.
+type symbolTrie struct {
+	children map[byte]*symbolTrie
+	isSymbol bool // the path to this node spells a symbol
+}
+
+func newSymbolTrie(symbols []string) *symbolTrie {
+	ret := &symbolTrie{children: map[byte]*symbolTrie{}}
+	for _, symbol := range symbols {
+		ret.insert(symbol)
 	}
+	return ret
+}
 
-	potentialKeywordSymbol := getKeywordSymbol(inputString, start)
-	if potentialKeywordSymbol != "" {
-		return potentialKeywordSymbol, start + len(potentialKeywordSymbol), nil
+func (t *symbolTrie) insert(symbol string) {
+	cur := t
+	for i := 0; i < len(symbol); i++ {
+		next, ok := cur.children[symbol[i]]
+		if !ok {
+			next = &symbolTrie{children: map[byte]*symbolTrie{}}
+			cur.children[symbol[i]] = next
+		}
+		cur = next
 	}
+	cur.isSymbol = true
+}
+
+// longestMatch returns the longest symbol in t which s has at start, or "" if there is none.
+func (t *symbolTrie) longestMatch(s string, start int) string {
+	end := start
+	cur := t
+	for i := start; cur != nil && i < len(s); i++ {
+		if cur = cur.children[s[i]]; cur != nil && cur.isSymbol {
+			end = i + 1
+		}
+	}
+	return s[start:end]
+}
+
+// builtinSymbols holds the builtin symbols and the Unicode aliases.
+var builtinSymbols = newSymbolTrie(builtinSymbolList())
 
-	if inputString[start] == ' ' {
-		return "", start + 1, nil
+func builtinSymbolList() []string {
+	ret := make([]string, 0, len(BuiltinSyms)+len(UnicodeAliases))
+	for symbol := range BuiltinSyms {
+		ret = append(ret, symbol)
 	}
+	for alias := range UnicodeAliases {
+		ret = append(ret, alias)
+	}
+	return ret
+}
+
+// scanner reads the tokens of a line in a single pass. It keeps the byte offset of the next rune
+// together with its column, so positions need no second pass over the line.
+type scanner struct {
+	src       string
+	pos       int
+	file      string
+	line      int
+	column    int
+	notations *NotationTable
+}
 
-	buffer := ""
-	for i := start; i < len(inputString); i++ {
-		if getKeywordSymbol(inputString, i) != "" || inputString[i] == ' ' {
+// scan returns the next token of the line, or false at the end of the line or of a comment.
+func (s *scanner) scan() (Token, bool, error) {
+	for s.pos < len(s.src) {
+		r, size := utf8.DecodeRuneInString(s.src[s.pos:])
+		if !isSpace(r) {
 			break
 		}
-		buffer += string(inputString[i])
+		s.pos += size
+		s.column++
+	}
+	if s.pos >= len(s.src) {
+		return Token{}, false, nil
 	}
-	return buffer, start + len(buffer), nil
-}
 
-func tokenizeString(inputString string) (*[]string, error) {
-	result := []string{}
-	buffer := ""
-	for i := 0; i < len(inputString); {
-		token, nextIndex, err := nextToken(inputString, i)
-		if err != nil {
-			return &result, err
+	// 如果下两个字符是 //，跳过直到结束
+	if strings.HasPrefix(s.src[s.pos:], "//") {
+		s.pos = len(s.src)
+		return Token{}, false, nil
+	}
+	// 如果下两个字符是 /*，报错
+	if strings.HasPrefix(s.src[s.pos:], "/*") {
+		return Token{}, false, s.errorf("invalid syntax: nested comment block")
+	}
+
+	start := s.pos
+	value, kind := "", TokenSymbol
+	if symbol := s.notations.symbolAt(s.src, s.pos); symbol != "" {
+		s.pos += len(symbol)
+		value = symbol
+		if alias, ok := UnicodeAliases[symbol]; ok {
+			value = alias
 		}
-		if token == "" {
-			if buffer != "" {
-				result = append(result, buffer)
-				buffer = ""
-			}
-		} else if getKeywordSymbol(inputString, i) != "" {
-			if buffer != "" {
-				result = append(result, buffer)
-				buffer = ""
+		if _, ok := Keywords[value]; ok && isWordSymbol(value) {
+			kind = TokenKeyword
+		}
+	} else {
+		r, size := utf8.DecodeRuneInString(s.src[s.pos:])
+		if r == utf8.RuneError && size == 1 {
+			return Token{}, false, s.errorf("invalid UTF-8 encoding")
+		}
+		s.pos += size
+		if isIdentifierRune(r) {
+			s.scanIdentifier()
+			kind = TokenIdentifier
+			if unicode.IsDigit(r) {
+				kind = TokenNumber
+			} else if _, ok := Keywords[s.src[start:s.pos]]; ok {
+				kind = TokenKeyword
 			}
-			result = append(result, token)
-		} else {
-			buffer = token
 		}
-		i = nextIndex
+		value = s.src[start:s.pos]
 	}
-	if buffer != "" {
-		result = append(result, buffer)
+
+	width := utf8.RuneCountInString(s.src[start:s.pos])
+	ret := Token{Value: value, Kind: kind, Span: Span{s.file, Position{s.line, s.column}, Position{s.line, s.column + width}}, notation: s.notations.get(value)}
+	s.column += width
+	return ret, true, nil
+}
+
+// scanIdentifier reads the rest of an identifier or a number: it runs until a rune which is
+// neither a letter nor a digit, or until a symbol.
+func (s *scanner) scanIdentifier() {
+	for s.pos < len(s.src) {
+		r, size := utf8.DecodeRuneInString(s.src[s.pos:])
+		if !isIdentifierRune(r) || s.notations.symbolAt(s.src, s.pos) != "" {
+			return
+		}
+		s.pos += size
+	}
+}
+
+func (s *scanner) errorf(format string, args ...any) error {
+	pos := Position{s.line, s.column}
+	return &Diagnostic{Code: DiagSyntaxError, Severity: SeverityError, Message: fmt.Sprintf(format, args...), Span: Span{s.file, pos, pos}}
+}
+
+func tokenizeString(inputString string) (*[]Token, error) {
+	return tokenizeLine(inputString, "", 1, 1, nil)
+}
+
+// tokenizeLine tokenizes inputString whose first rune is at the given line and column of file.
+// Symbols of notations declared in notations are tokens of their own.
+func tokenizeLine(inputString string, file string, line int, column int, notations *NotationTable) (*[]Token, error) {
+	result, err := appendLineTokens(make([]Token, 0, len(inputString)/4+1), inputString, file, line, column, notations)
+	return &result, err
+}
+
+// appendLineTokens appends the tokens of inputString to tokens, like tokenizeLine.
+func appendLineTokens(tokens []Token, inputString string, file string, line int, column int, notations *NotationTable) ([]Token, error) {
+	s := scanner{src: inputString, file: file, line: line, column: column, notations: notations}
+	for {
+		token, ok, err := s.scan()
+		if err != nil || !ok {
+			return tokens, err
+		}
+		tokens = append(tokens, token)
 	}
-	return &result, nil
 }
 
 func TokenizeStmtBlock(b *strBlock) (*TokenBlock, error) {
-	body := []TokenBlock{}
+	return tokenizeStmtBlock(b, "", nil)
+}
 
-	// 这里假设我们需要对输入的 StrArrStmtBlock 的 Header 进行一些处理
-	// 例如，将 Header 中的元素转换为大写
-	headerPtr, err := tokenizeString(b.header)
-	header := *headerPtr
+// tokenizeStmtBlock tokenizes b and its body. The headers of all the blocks share one slice of
+// tokens. A token takes at least one byte of the headers, so the slice never grows.
+func tokenizeStmtBlock(b *strBlock, file string, notations *NotationTable) (*TokenBlock, error) {
+	tokens := make([]Token, 0, blockSourceLen(b))
+	return appendBlockTokens(&tokens, b, file, notations)
+}
 
-	if err != nil || header == nil {
+// blockSourceLen is the number of bytes of the headers of b and of its body.
+func blockSourceLen(b *strBlock) int {
+	n := len(b.header)
+	for i := range b.body {
+		n += blockSourceLen(&b.body[i])
+	}
+	return n
+}
+
+func appendBlockTokens(tokens *[]Token, b *strBlock, file string, notations *NotationTable) (*TokenBlock, error) {
+	start := len(*tokens)
+	var err error
+	*tokens, err = appendLineTokens(*tokens, b.header, file, b.line, b.column, notations)
+	if err != nil {
 		return nil, err
 	}
+	// the full slice expression keeps a later append from writing over the tokens of the next header
+	header := (*tokens)[start:len(*tokens):len(*tokens)]
+	if len(header) > 0 {
+		header[0].doc = b.doc
+	}
 
-	// 这里假设我们需要对输入的 StrArrStmtBlock 的 Body 进行一些处理
-	// 例如，递归调用 ParseStmtBlock 处理 Body 中的每个元素
-	for _, subBlock := range b.body {
-		parsedSubBlock, err := TokenizeStmtBlock(&subBlock)
+	body := make([]TokenBlock, 0, len(b.body))
+	for i := range b.body {
+		parsedSubBlock, err := appendBlockTokens(tokens, &b.body[i], file, notations)
 		if err != nil {
 			return nil, err
 		}
@@ -6,33 +6,53 @@ import (
 	memory "golitex/litex_memory"
 	pack "golitex/litex_package_management"
 	parser "golitex/litex_parser"
+	"sync"
 )
 
+// An Env may be read by many goroutines at once, e.g. by child envs which verify claims in parallel.
+// Methods which write take the write lock of the env, so there is a single writer per env at a time.
+// Parent and SkipDuplicateFacts must be set before the env is shared.
 type Env struct {
 	Parent         *Env
-	VarMemory      memory.VarMemory
-	PropMemory     memory.PropMemory
-	FnMemory       memory.FnMemory
-	AliasMemory    memory.AliasMemory
-	SpecFactMemory memory.SpecFactMemory
-	CondFactMemory memory.CondFactMemory
-	UniFactMemory  memory.UniFactMemory
-	VarTypeMemory  memory.FcVarTypeMemory
+	VarMemory      *memory.VarMemory
+	PropMemory     *memory.PropMemory
+	FnMemory       *memory.FnMemory
+	AliasMemory    *memory.AliasMemory
+	SpecFactMemory *memory.SpecFactMemory
+	CondFactMemory *memory.CondFactMemory
+	UniFactMemory  *memory.UniFactMemory
+	VarTypeMemory  *memory.FcVarTypeMemory
+	TypeMemory     *memory.TypeMemory
+	ImportedPacks  *pack.PackDict
+
+	SkipDuplicateFacts bool // when set, NewFact does not store facts which are already known
+
+	writeMu sync.Mutex // a check such as isNameUsed and the write which follows it are done under writeMu
 }
 
 func NewEnv() *Env {
 	return &Env{
 		Parent:         nil,
-		VarMemory:      *memory.NewVarMemory(),
-		PropMemory:     *memory.NewPropMemory(),
-		FnMemory:       *memory.NewFnMemory(),
-		AliasMemory:    *memory.NewAliasMemory(),
-		SpecFactMemory: *memory.NewSpecFactMemory(),
-		UniFactMemory:  *memory.NewUniFactMemory(),
-		VarTypeMemory:  *memory.NewFcVarTypeMemory(),
+		VarMemory:      memory.NewVarMemory(),
+		PropMemory:     memory.NewPropMemory(),
+		FnMemory:       memory.NewFnMemory(),
+		AliasMemory:    memory.NewAliasMemory(),
+		SpecFactMemory: memory.NewSpecFactMemory(),
+		CondFactMemory: memory.NewCondFactMemory(),
+		UniFactMemory:  memory.NewUniFactMemory(),
+		VarTypeMemory:  memory.NewFcVarTypeMemory(),
+		TypeMemory:     memory.NewTypeMemory(),
+		ImportedPacks:  pack.NewPackDict(),
 	}
 }
 
+// NewChildEnv returns an env whose lookups fall back to parent. Several child envs can share a parent.
+func NewChildEnv(parent *Env) *Env {
+	ret := NewEnv()
+	ret.Parent = parent
+	return ret
+}
+
 func (env *Env) isNameUsed(name string) (bool, error) {
 	if _, ok := parser.Keywords[name]; ok {
 		return true, fmt.Errorf("%v is a reserved keyword", name)
@@ -42,46 +62,240 @@ func (env *Env) isNameUsed(name string) (bool, error) {
 		return true, fmt.Errorf("%v is a reserved symbol", name)
 	}
 
-	if _, got := env.VarMemory.Get(name); got {
+	if _, got := env.GetVar(name); got {
+		return true, fmt.Errorf("%v is already defined", name)
+	}
+
+	if _, got := env.GetFn(name); got {
 		return true, fmt.Errorf("%v is already defined", name)
 	}
 
-	if _, got := env.FnMemory.Get(name); got {
+	if _, got := env.GetProp(name); got {
 		return true, fmt.Errorf("%v is already defined", name)
 	}
 
-	if _, got := env.PropMemory.Get(name); got {
+	if _, got := env.GetAlias(name); got {
 		return true, fmt.Errorf("%v is already defined", name)
 	}
 
-	if _, got := env.AliasMemory.Get(name); got {
+	if _, got := env.GetType(name); got {
 		return true, fmt.Errorf("%v is already defined", name)
 	}
 
-	if _, got := pack.ImportedPackDict.Get(name); got {
+	if _, got := env.GetPack(name); got {
 		return true, fmt.Errorf("%v is already imported", name)
 	}
 
 	return false, nil
 }
 
-func (e *Env) isVarDefined(name string) bool {
-	_, ok := e.VarMemory.Get(name)
-	if ok {
+// isDeclared tells whether name is declared in env or in one of its parents.
+func (env *Env) isDeclared(name string) bool {
+	if _, got := env.GetVar(name); got {
+		return true
+	}
+	if _, got := env.GetFn(name); got {
 		return true
+	}
+	if _, got := env.GetProp(name); got {
+		return true
+	}
+	if _, got := env.GetAlias(name); got {
+		return true
+	}
+	if _, got := env.GetType(name); got {
+		return true
+	}
+	_, got := env.GetPack(name)
+	return got
+}
+
+// Get methods below search the current env first and then its parents.
+
+func (e *Env) GetVar(name string) (*memory.VarMemoryEntry, bool) {
+	entry, ok := e.VarMemory.Get(name)
+	if ok {
+		return entry, true
+	} else {
+		if e.Parent != nil {
+			return e.Parent.GetVar(name)
+		}
+		return nil, false
+	}
+}
+
+func (e *Env) GetProp(name string) (*memory.PropMemoryEntry, bool) {
+	entry, ok := e.PropMemory.Get(name)
+	if ok {
+		return entry, true
+	} else {
+		if e.Parent != nil {
+			return e.Parent.GetProp(name)
+		}
+		return nil, false
+	}
+}
+
+func (e *Env) GetFn(name string) (*memory.FnMemEntry, bool) {
+	entry, ok := e.FnMemory.Get(name)
+	if ok {
+		return entry, true
+	} else {
+		if e.Parent != nil {
+			return e.Parent.GetFn(name)
+		}
+		return nil, false
+	}
+}
+
+func (e *Env) GetAlias(name string) (*memory.AliasMemEntry, bool) {
+	entry, ok := e.AliasMemory.Get(name)
+	if ok {
+		return entry, true
+	} else {
+		if e.Parent != nil {
+			return e.Parent.GetAlias(name)
+		}
+		return nil, false
+	}
+}
+
+func (e *Env) GetPack(name string) (*pack.Pack, bool) {
+	entry, ok := e.ImportedPacks.Get(name)
+	if ok {
+		return entry, true
+	} else {
+		if e.Parent != nil {
+			return e.Parent.GetPack(name)
+		}
+		return nil, false
+	}
+}
+
+func (e *Env) GetType(name string) (*memory.TypeMemEntry, bool) {
+	entry, ok := e.TypeMemory.Get(name)
+	if ok {
+		return entry, true
 	} else {
 		if e.Parent != nil {
-			return e.Parent.isVarDefined(name)
+			return e.Parent.GetType(name)
 		}
-		return false
+		return nil, false
 	}
 }
 
+// isType tells whether name is the name of a type known by e, which normalization needs to tell
+// Real.__add__(a, b) from x.__add__(b).
+func (e *Env) isType(name string) bool {
+	_, ok := e.GetType(name)
+	return ok
+}
+
 func (e *Env) NewVar(pair *parser.FcVarDeclPair) error {
-	if e.isVarDefined(pair.Var) {
-		return fmt.Errorf("%v is defined", pair.Var)
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+
+	if _, err := e.isNameUsed(pair.Var); err != nil {
+		return err
+	}
+
+	if _, err := e.VarMemory.Set(pair); err != nil {
+		return err
+	}
+	e.VarTypeMemory.Add(pair.Tp, pair.Var)
+	return nil
+}
+
+func (e *Env) NewProp(decl *parser.PropDecl) error {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+
+	if _, err := e.isNameUsed(decl.Name); err != nil {
+		return err
+	}
+
+	_, err := e.PropMemory.Set(decl)
+	return err
+}
+
+// NewExistProp declares the prop of an exist statement, with the members and facts have gives.
+func (e *Env) NewExistProp(stmt *parser.DefExistStmt) error {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+
+	if _, err := e.isNameUsed(stmt.Decl.Name); err != nil {
+		return err
+	}
+
+	_, err := e.PropMemory.SetExist(stmt)
+	return err
+}
+
+func (e *Env) NewFn(decl *parser.FcFnDecl) error {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+
+	if _, err := e.isNameUsed(decl.Name); err != nil {
+		return err
 	}
 
-	_, err := e.VarMemory.Set(pair)
+	_, err := e.FnMemory.Set(decl)
 	return err
 }
+
+func (e *Env) NewAlias(stmt *parser.DefAliasStmt) error {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+
+	if _, err := e.isNameUsed(stmt.NewName); err != nil {
+		return err
+	}
+
+	if !e.isDeclared(stmt.PreviousName) {
+		return fmt.Errorf("%v is not defined", stmt.PreviousName)
+	}
+
+	_, err := e.AliasMemory.Set(stmt)
+	return err
+}
+
+// NewFact stores fact and returns how it relates to the facts known before, as reported by CheckNewFact.
+// Facts are stored normalized by parser.NormalizeFact, so operators written as member function calls
+// match operators written in operator form.
+func (e *Env) NewFact(fact parser.FactStmt, provenance memory.FactProvenance) ([]memory.FactRedundancy, error) {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+
+	fact = parser.NormalizeFact(fact, e.isType)
+
+	// x is T gives x the type T, and is known as a fact as well
+	if name, tp, ok := e.getVarTypeFact(fact); ok {
+		if err := e.newVarType(name, tp); err != nil {
+			return nil, err
+		}
+	}
+
+	redundancies, err := e.CheckNewFact(fact)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.SkipDuplicateFacts && len(redundancies) > 0 && redundancies[0].Kind == memory.FactDuplicate {
+		return redundancies, nil
+	}
+
+	return redundancies, e.storeFact(fact, provenance)
+}
+
+func (e *Env) storeFact(fact parser.FactStmt, provenance memory.FactProvenance) error {
+	switch fact := fact.(type) {
+	case parser.SpecFactStmt:
+		return e.SpecFactMemory.NewFact(fact, provenance)
+	case *parser.IfFactStmt:
+		return e.CondFactMemory.NewFact(fact, provenance)
+	case *parser.BlockForallStmt:
+		return e.UniFactMemory.NewFact(fact, provenance)
+	}
+
+	return fmt.Errorf("unknown fact type: %T", fact)
+}
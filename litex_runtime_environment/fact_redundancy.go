@@ -0,0 +1,180 @@
+package litexenv
+
+import (
+	memory "golitex/litex_memory"
+	parser "golitex/litex_parser"
+)
+
+// CheckNewFact reports how fact relates to the facts known by e and its parents. A duplicate is reported alone, and so are
+// the universal facts which imply fact. Otherwise the result lists the older facts which fact makes redundant.
+func (e *Env) CheckNewFact(fact parser.FactStmt) ([]memory.FactRedundancy, error) {
+	fact = parser.NormalizeFact(fact, e.isType)
+	duplicate, err := e.getDuplicateFact(fact)
+	if err != nil {
+		return nil, err
+	}
+	if duplicate != nil {
+		return []memory.FactRedundancy{*duplicate}, nil
+	}
+
+	ret := []memory.FactRedundancy{}
+	chain := e.envChain()
+
+	switch fact := fact.(type) {
+	case parser.SpecFactStmt:
+		implying, err := e.getImplyingUniFacts([]parser.SpecFactStmt{fact}, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(implying) > 0 {
+			return implying, nil
+		}
+
+		for _, cur := range chain {
+			subsumed, err := cur.CondFactMemory.GetFactsConcluding(fact)
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, subsumed...)
+		}
+
+	case *parser.IfFactStmt:
+		return e.getImplyingUniFacts(fact.ThenFacts, fact.CondFacts)
+
+	case *parser.BlockForallStmt:
+		cond, then, err := withFreshParams(fact)
+		if err != nil {
+			return nil, err
+		}
+		implying, err := e.getImplyingUniFacts(then, cond)
+		if err != nil {
+			return nil, err
+		}
+		if len(implying) > 0 {
+			return implying, nil
+		}
+
+		for _, cur := range chain {
+			subsumed, err := cur.SpecFactMemory.GetInstancesOf(fact, e.specFactIsKnownUnder(nil))
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, subsumed...)
+		}
+	}
+
+	return ret, nil
+}
+
+// withFreshParams returns the conds and then facts of fact with its parameters renamed to names
+// which no known fact mentions, so that a known fact about a name which fact binds is not taken as
+// a fact about its parameter.
+func withFreshParams(fact *parser.BlockForallStmt) ([]parser.FactStmt, []parser.SpecFactStmt, error) {
+	subst := map[string]parser.Fc{}
+	for _, param := range fact.VarParams {
+		// # is not part of any name in source code
+		subst[param.Var] = parser.FcStr{Value: "#" + param.Var}
+	}
+
+	cond := []parser.FactStmt{}
+	for _, cur := range fact.Cond {
+		renamed, err := parser.SubstituteFact(cur, subst)
+		if err != nil {
+			return nil, nil, err
+		}
+		cond = append(cond, renamed)
+	}
+	then := []parser.SpecFactStmt{}
+	for _, cur := range fact.Then {
+		renamed, err := parser.SubstituteSpecFact(cur, subst)
+		if err != nil {
+			return nil, nil, err
+		}
+		then = append(then, renamed)
+	}
+	return cond, then, nil
+}
+
+func (e *Env) getDuplicateFact(fact parser.FactStmt) (*memory.FactRedundancy, error) {
+	for _, cur := range e.envChain() {
+		var duplicate *memory.FactRedundancy
+		var err error
+
+		switch fact := fact.(type) {
+		case parser.SpecFactStmt:
+			duplicate, err = cur.SpecFactMemory.GetDuplicate(fact)
+		case *parser.IfFactStmt:
+			duplicate, err = cur.CondFactMemory.GetDuplicate(fact)
+		case *parser.BlockForallStmt:
+			duplicate, err = cur.UniFactMemory.GetDuplicate(fact)
+		}
+
+		if err != nil || duplicate != nil {
+			return duplicate, err
+		}
+	}
+	return nil, nil
+}
+
+// getImplyingUniFacts returns one implying universal fact for each goal when every goal is implied, and nothing otherwise.
+// hypotheses are known in addition to the known spec facts, e.g. the conds of a new forall.
+func (e *Env) getImplyingUniFacts(goals []parser.SpecFactStmt, hypotheses []parser.FactStmt) ([]memory.FactRedundancy, error) {
+	isKnown := e.specFactIsKnownUnder(hypotheses)
+	ret := []memory.FactRedundancy{}
+
+	for _, goal := range goals {
+		var implying *memory.FactRedundancy
+		for _, cur := range e.envChain() {
+			var err error
+			implying, err = cur.UniFactMemory.GetImplyingFact(goal, isKnown)
+			if err != nil {
+				return nil, err
+			}
+			if implying != nil {
+				break
+			}
+		}
+
+		if implying == nil {
+			return []memory.FactRedundancy{}, nil
+		}
+		ret = append(ret, *implying)
+	}
+
+	return ret, nil
+}
+
+// IsSpecFactKnown tells whether fact is stored in e or one of its parents.
+func (e *Env) IsSpecFactKnown(fact parser.SpecFactStmt) (bool, error) {
+	normalized, ok := parser.NormalizeFact(fact, e.isType).(parser.SpecFactStmt)
+	if !ok {
+		return false, nil
+	}
+	return e.specFactIsKnownUnder(nil)(normalized)
+}
+
+func (e *Env) specFactIsKnownUnder(hypotheses []parser.FactStmt) func(parser.SpecFactStmt) (bool, error) {
+	return func(fact parser.SpecFactStmt) (bool, error) {
+		for _, hypothesis := range hypotheses {
+			specHypothesis, ok := hypothesis.(parser.SpecFactStmt)
+			if !ok {
+				continue
+			}
+			comp, err := memory.SpecFactCompare(&specHypothesis, &fact)
+			if err != nil {
+				return false, err
+			}
+			if comp == 0 {
+				return true, nil
+			}
+		}
+
+		for _, cur := range e.envChain() {
+			_, ok, err := cur.SpecFactMemory.GetProvenance(fact)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	}
+}
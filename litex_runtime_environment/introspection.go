@@ -0,0 +1,243 @@
+package litexenv
+
+import (
+	memory "golitex/litex_memory"
+	parser "golitex/litex_parser"
+	"strings"
+)
+
+// Methods below let tools and tests look at what an Env knows without reaching into its memories.
+// Except for FactCount, they look at the current env and all of its parents, from the outermost env inwards.
+
+// FactCount is the number of entries in each memory of a single env.
+type FactCount struct {
+	Types     int
+	Vars      int
+	Props     int
+	Fns       int
+	Aliases   int
+	SpecFacts int
+	CondFacts int
+	UniFacts  int
+}
+
+func (e *Env) envChain() []*Env {
+	if e.Parent == nil {
+		return []*Env{e}
+	}
+	return append(e.Parent.envChain(), e)
+}
+
+// FactCount counts the entries of the memories of e, without those of its parents.
+func (e *Env) FactCount() FactCount {
+	return FactCount{
+		Types:     e.TypeMemory.Len(),
+		Vars:      e.VarMemory.Len(),
+		Props:     e.PropMemory.Len(),
+		Fns:       e.FnMemory.Len(),
+		Aliases:   e.AliasMemory.Len(),
+		SpecFacts: e.SpecFactMemory.Len(),
+		CondFacts: e.CondFactMemory.Len(),
+		UniFacts:  e.UniFactMemory.Len(),
+	}
+}
+
+// FactsOfProp lists the known facts whose conclusion is about the prop (or relational operator) of the given name.
+func (e *Env) FactsOfProp(name string) ([]parser.FactStmt, error) {
+	ret := []parser.FactStmt{}
+	for _, cur := range e.envChain() {
+		specFacts, err := cur.SpecFactMemory.GetFactsOfProp(memory.PropName(name))
+		if err != nil {
+			return nil, err
+		}
+		for _, fact := range specFacts {
+			ret = append(ret, fact)
+		}
+		for _, fact := range cur.CondFactMemory.GetFactsOfProp(memory.PropName(name)) {
+			ret = append(ret, fact)
+		}
+		for _, fact := range cur.UniFactMemory.GetFactsOfProp(memory.PropName(name)) {
+			ret = append(ret, fact)
+		}
+	}
+	return ret, nil
+}
+
+// FactsAbout lists the known facts in which fc appears.
+func (e *Env) FactsAbout(fc parser.Fc) ([]parser.FactStmt, error) {
+	fc = parser.NormalizeFc(fc, e.isType)
+	ret := []parser.FactStmt{}
+	for _, cur := range e.envChain() {
+		specFacts, err := cur.SpecFactMemory.GetFactsAbout(fc)
+		if err != nil {
+			return nil, err
+		}
+		for _, fact := range specFacts {
+			ret = append(ret, fact)
+		}
+
+		condFacts, err := cur.CondFactMemory.GetFactsAbout(fc)
+		if err != nil {
+			return nil, err
+		}
+		for _, fact := range condFacts {
+			ret = append(ret, fact)
+		}
+
+		uniFacts, err := cur.UniFactMemory.GetFactsAbout(fc)
+		if err != nil {
+			return nil, err
+		}
+		for _, fact := range uniFacts {
+			ret = append(ret, fact)
+		}
+	}
+	return ret, nil
+}
+
+// Dump prints everything e knows as Litex source: declarations first and then a know block of all facts.
+// Types given to vars after their declaration are printed as facts such as $Nat(x), i.e. x is Nat.
+// The result parses with parser.ParseSourceCode. Facts of different envs are merged when the dump is executed,
+// so only the dump of an env without parents is guaranteed to be reproduced by executing it.
+func (e *Env) Dump() (string, error) {
+	types := []typeDecl{}
+	vars, props, fns, aliases := []string{}, []string{}, []string{}, []string{}
+	specFacts, condFacts, uniFacts := []parser.FactStmt{}, []parser.FactStmt{}, []parser.FactStmt{}
+
+	for _, cur := range e.envChain() {
+		err := cur.TypeMemory.Traverse(func(name string, entry *memory.TypeMemEntry) error {
+			types = append(types, typeDecl{name, entry})
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+
+		err = cur.VarMemory.Traverse(func(name string, entry *memory.VarMemoryEntry) error {
+			vars = append(vars, (&parser.FcVarDeclPair{Var: name, Tp: entry.Tp}).String())
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+
+		err = cur.VarTypeMemory.Traverse(func(tp string, names []string) error {
+			for _, name := range names {
+				entry, ok := cur.GetVar(name)
+				if !ok || entry.Tp.String() == tp {
+					continue
+				}
+				// a type given by a fact is dumped with the other spec facts
+				fact := newVarTypeFact(name, tp)
+				if _, known, err := cur.SpecFactMemory.GetProvenance(fact); err != nil {
+					return err
+				} else if !known {
+					specFacts = append(specFacts, fact)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+
+		err = cur.PropMemory.Traverse(func(name string, entry *memory.PropMemoryEntry) error {
+			props = append(props, propDeclSource(entry))
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+
+		err = cur.FnMemory.Traverse(func(name string, entry *memory.FnMemEntry) error {
+			fns = append(fns, entry.Decl.String())
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+
+		err = cur.AliasMemory.Traverse(func(name string, entry *memory.AliasMemEntry) error {
+			for _, value := range *entry.Values {
+				aliases = append(aliases, (&parser.DefAliasStmt{PreviousName: value, NewName: name}).String())
+			}
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+
+		err = cur.SpecFactMemory.Traverse(func(fact parser.SpecFactStmt, _ memory.FactProvenance) error {
+			specFacts = append(specFacts, fact)
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+
+		err = cur.CondFactMemory.Traverse(func(fact *parser.IfFactStmt, _ memory.FactProvenance) error {
+			condFacts = append(condFacts, fact)
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+
+		err = cur.UniFactMemory.Traverse(func(fact *parser.BlockForallStmt, _ memory.FactProvenance) error {
+			uniFacts = append(uniFacts, fact)
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	ret := append(append(append(append(typeDeclSources(types), vars...), props...), fns...), aliases...)
+	know := &parser.KnowStmt{Facts: append(append(specFacts, condFacts...), uniFacts...)}
+	if len(know.Facts) > 0 {
+		ret = append(ret, know.String())
+	}
+
+	return strings.Join(ret, "\n") + "\n", nil
+}
+
+type typeDecl struct {
+	name  string
+	entry *memory.TypeMemEntry
+}
+
+// typeDeclSources prints the declarations so that a type is declared after the types it extends
+func typeDeclSources(types []typeDecl) []string {
+	ret := []string{}
+	declared := map[string]struct{}{}
+
+	var declare func(decl typeDecl)
+	declare = func(decl typeDecl) {
+		if _, ok := declared[decl.name]; ok {
+			return
+		}
+		declared[decl.name] = struct{}{}
+
+		for _, extended := range decl.entry.Extends {
+			for _, cur := range types {
+				if cur.name == extended {
+					declare(cur)
+				}
+			}
+		}
+		ret = append(ret, typeDeclSource(decl.name, decl.entry))
+	}
+
+	for _, decl := range types {
+		declare(decl)
+	}
+	return ret
+}
+
+// propDeclSource prints a prop declared in PropMemory as the statement which declares it
+func propDeclSource(entry *memory.PropMemoryEntry) string {
+	if entry.Exist != nil {
+		return entry.Exist.String()
+	}
+	return entry.Decl.String()
+}
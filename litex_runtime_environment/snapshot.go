@@ -0,0 +1,342 @@
+package litexenv
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	memory "golitex/litex_memory"
+	parser "golitex/litex_parser"
+	"os"
+	"strconv"
+)
+
+// A snapshot stores everything an Env knows so that a verified library does not have to be verified again.
+// Declarations and facts are stored as Litex source and parsed back when the snapshot is loaded.
+
+// SnapshotVersion is bumped whenever the snapshot format changes. Snapshots of any other version are rejected.
+const SnapshotVersion = 4
+
+type envSnapshot struct {
+	Version        int               `json:"version"`
+	SourceChecksum string            `json:"source_checksum"`
+	Types          []string          `json:"types"`
+	Vars           []varSnapshot     `json:"vars"`
+	Props          []string          `json:"props"`
+	Fns            []string          `json:"fns"`
+	Aliases        []string          `json:"aliases"`
+	SpecFacts      []factSnapshot    `json:"spec_facts"`
+	CondFacts      []factSnapshot    `json:"cond_facts"`
+	UniFacts       []factSnapshot    `json:"uni_facts"`
+	VarTypes       []varTypeSnapshot `json:"var_types"`
+}
+
+type varSnapshot struct {
+	Name  string   `json:"name"`
+	Tp    string   `json:"tp"`
+	Types []string `json:"types"`
+}
+
+type factSnapshot struct {
+	Fact       string                `json:"fact"`
+	Provenance memory.FactProvenance `json:"provenance"`
+}
+
+// varTypeSnapshot is an entry of the reverse index from types to vars
+type varTypeSnapshot struct {
+	Type string   `json:"type"`
+	Vars []string `json:"vars"`
+}
+
+// SourceChecksum hashes the contents of the source files, in the given order, that produced an Env.
+func SourceChecksum(sourceFiles []string) (string, error) {
+	hash := sha256.New()
+	for _, sourceFile := range sourceFiles {
+		content, err := os.ReadFile(sourceFile)
+		if err != nil {
+			return "", err
+		}
+		hash.Write([]byte(strconv.Itoa(len(content)) + "\n"))
+		hash.Write(content)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Save writes the memories of env, without those of its parents, to path.
+func (env *Env) Save(path string, sourceFiles []string) error {
+	checksum, err := SourceChecksum(sourceFiles)
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := env.snapshot()
+	if err != nil {
+		return err
+	}
+	snapshot.SourceChecksum = checksum
+
+	// facts such as a < b are easier to read without escaping
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(snapshot); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, content.Bytes(), 0644)
+}
+
+// Load reads an Env saved by Save. The snapshot is rejected if it is of another version or if the source files changed.
+func Load(path string, sourceFiles []string) (*Env, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := envSnapshot{}
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %v", path, err)
+	}
+
+	if snapshot.Version != SnapshotVersion {
+		return nil, fmt.Errorf("snapshot %s is of version %d, expected version %d", path, snapshot.Version, SnapshotVersion)
+	}
+
+	checksum, err := SourceChecksum(sourceFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	if snapshot.SourceChecksum != checksum {
+		return nil, fmt.Errorf("snapshot %s is stale: source files changed since it was saved", path)
+	}
+
+	return snapshot.env()
+}
+
+func (env *Env) snapshot() (*envSnapshot, error) {
+	ret := &envSnapshot{Version: SnapshotVersion, Types: []string{}, Vars: []varSnapshot{}, Props: []string{}, Fns: []string{}, Aliases: []string{}, SpecFacts: []factSnapshot{}, CondFacts: []factSnapshot{}, UniFacts: []factSnapshot{}, VarTypes: []varTypeSnapshot{}}
+
+	err := env.TypeMemory.Traverse(func(name string, entry *memory.TypeMemEntry) error {
+		ret.Types = append(ret.Types, typeDeclSource(name, entry))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = env.VarMemory.Traverse(func(name string, entry *memory.VarMemoryEntry) error {
+		types := []string{}
+		for _, tp := range entry.Types {
+			types = append(types, tp.String())
+		}
+		ret.Vars = append(ret.Vars, varSnapshot{name, entry.Tp.String(), types})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = env.PropMemory.Traverse(func(name string, entry *memory.PropMemoryEntry) error {
+		ret.Props = append(ret.Props, propDeclSource(entry))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = env.FnMemory.Traverse(func(name string, entry *memory.FnMemEntry) error {
+		ret.Fns = append(ret.Fns, entry.Decl.String())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = env.AliasMemory.Traverse(func(name string, entry *memory.AliasMemEntry) error {
+		for _, value := range *entry.Values {
+			ret.Aliases = append(ret.Aliases, (&parser.DefAliasStmt{PreviousName: value, NewName: name}).String())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = env.SpecFactMemory.Traverse(func(fact parser.SpecFactStmt, provenance memory.FactProvenance) error {
+		ret.SpecFacts = append(ret.SpecFacts, factSnapshot{fact.String(), provenance})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = env.CondFactMemory.Traverse(func(fact *parser.IfFactStmt, provenance memory.FactProvenance) error {
+		ret.CondFacts = append(ret.CondFacts, factSnapshot{fact.String(), provenance})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = env.UniFactMemory.Traverse(func(fact *parser.BlockForallStmt, provenance memory.FactProvenance) error {
+		ret.UniFacts = append(ret.UniFacts, factSnapshot{fact.String(), provenance})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = env.VarTypeMemory.Traverse(func(tp string, vars []string) error {
+		ret.VarTypes = append(ret.VarTypes, varTypeSnapshot{tp, vars})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+func (snapshot *envSnapshot) env() (*Env, error) {
+	env := NewEnv()
+
+	for _, s := range snapshot.Types {
+		stmt, err := parseSnapshotStmt(s)
+		if err != nil {
+			return nil, err
+		}
+		defType, ok := stmt.(*parser.DefTypeStmt)
+		if !ok {
+			return nil, fmt.Errorf("invalid type in snapshot: %s", s)
+		}
+		name, extends, err := getDefTypeStmtNames(defType)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := env.TypeMemory.Set(name, extends); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, v := range snapshot.Vars {
+		tp, err := parseSnapshotVarType(v.Tp)
+		if err != nil {
+			return nil, err
+		}
+
+		types := []parser.FcVarType{}
+		for _, s := range v.Types {
+			cur, err := parseSnapshotVarType(s)
+			if err != nil {
+				return nil, err
+			}
+			types = append(types, *cur)
+		}
+
+		env.VarMemory.SetEntry(v.Name, memory.VarMemoryEntry{Tp: *tp, Types: types})
+	}
+
+	for _, s := range snapshot.Props {
+		stmt, err := parseSnapshotStmt(s)
+		if err != nil {
+			return nil, err
+		}
+		switch prop := stmt.(type) {
+		case *parser.DefPropStmt:
+			_, err = env.PropMemory.Set(&prop.Decl)
+		case *parser.DefExistStmt:
+			_, err = env.PropMemory.SetExist(prop)
+		default:
+			err = fmt.Errorf("invalid prop in snapshot: %s", s)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range snapshot.Fns {
+		stmt, err := parseSnapshotStmt(s)
+		if err != nil {
+			return nil, err
+		}
+		fn, ok := stmt.(*parser.DefFnStmt)
+		if !ok {
+			return nil, fmt.Errorf("invalid fn in snapshot: %s", s)
+		}
+		if _, err := env.FnMemory.Set(&parser.FcFnDecl{Name: fn.Name, Tp: fn.Tp}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range snapshot.Aliases {
+		stmt, err := parseSnapshotStmt(s)
+		if err != nil {
+			return nil, err
+		}
+		alias, ok := stmt.(*parser.DefAliasStmt)
+		if !ok {
+			return nil, fmt.Errorf("invalid alias in snapshot: %s", s)
+		}
+		if _, err := env.AliasMemory.Set(alias); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, facts := range [][]factSnapshot{snapshot.SpecFacts, snapshot.CondFacts, snapshot.UniFacts} {
+		for _, s := range facts {
+			stmt, err := parseSnapshotStmt(s.Fact)
+			if err != nil {
+				return nil, err
+			}
+			fact, ok := stmt.(parser.FactStmt)
+			if !ok {
+				return nil, fmt.Errorf("invalid fact in snapshot: %s", s.Fact)
+			}
+			if err := env.storeFact(fact, s.Provenance); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, v := range snapshot.VarTypes {
+		tp, err := parseSnapshotVarType(v.Type)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range v.Vars {
+			env.VarTypeMemory.Add(*tp, name)
+		}
+	}
+
+	return env, nil
+}
+
+func parseSnapshotStmt(code string) (parser.Stmt, error) {
+	stmts, err := parser.ParseSourceCode(code)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(*stmts) != 1 {
+		return nil, fmt.Errorf("expected one statement in snapshot, got %d: %s", len(*stmts), code)
+	}
+
+	return (*stmts)[0].Stmt, nil
+}
+
+func parseSnapshotVarType(tp string) (*parser.FcVarType, error) {
+	stmt, err := parseSnapshotStmt(fmt.Sprintf("%s _ %s", parser.Keywords["var"], tp))
+	if err != nil {
+		return nil, err
+	}
+
+	v, ok := stmt.(*parser.DefVarStmt)
+	if !ok {
+		return nil, fmt.Errorf("invalid type in snapshot: %s", tp)
+	}
+
+	return &v.Decl.VarTypePair.Tp, nil
+}
@@ -0,0 +1,186 @@
+package litexenv
+
+import (
+	"fmt"
+	memory "golitex/litex_memory"
+	parser "golitex/litex_parser"
+	"strings"
+)
+
+// A var can have several types: the type it is declared with and the types given to it by facts such as
+// know x is Nat, where Nat is a declared type. A var also has every type which one of its types extends.
+
+// NewType declares a type. type impl Real Nat declares Nat, which extends Real.
+func (e *Env) NewType(stmt *parser.DefTypeStmt) error {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+
+	name, extends, err := getDefTypeStmtNames(stmt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := e.isNameUsed(name); err != nil {
+		return err
+	}
+
+	for _, extended := range extends {
+		if _, ok := e.GetType(extended); !ok {
+			return fmt.Errorf("%s extends undeclared type %s", name, extended)
+		}
+	}
+
+	_, err = e.TypeMemory.Set(name, extends)
+	return err
+}
+
+// getDefTypeStmtNames returns the name of the declared type and the names of the types it extends
+func getDefTypeStmtNames(stmt *parser.DefTypeStmt) (string, []string, error) {
+	decl, ok := stmt.Decl.(*parser.FcVarDecl)
+	if !ok {
+		return "", nil, fmt.Errorf("only types of vars can be declared, got %T", stmt.Decl)
+	}
+
+	extends := []string{}
+	if len(stmt.ImplType.TypeNameArr) > 0 {
+		extends = append(extends, strings.Join(stmt.ImplType.TypeNameArr, parser.BuiltinSyms["."]))
+	}
+
+	return decl.VarTypePair.Tp.String(), extends, nil
+}
+
+// NewVarType gives one more type to a var. The type is only known in e and its children, even if the var is declared in a parent.
+func (e *Env) NewVarType(name string, tp parser.FcVarType) error {
+	e.writeMu.Lock()
+	defer e.writeMu.Unlock()
+
+	return e.newVarType(name, tp)
+}
+
+func (e *Env) newVarType(name string, tp parser.FcVarType) error {
+	if _, ok := e.GetVar(name); !ok {
+		return fmt.Errorf("%s is not a var", name)
+	}
+
+	if _, ok := e.VarMemory.Get(name); ok {
+		if _, err := e.VarMemory.AddType(name, tp); err != nil {
+			return err
+		}
+	}
+
+	e.VarTypeMemory.Add(tp, name)
+	return nil
+}
+
+// GetVarTypes returns the types a var has directly, without the types they extend.
+func (e *Env) GetVarTypes(name string) []string {
+	ret := []string{}
+	seen := map[string]struct{}{}
+
+	if entry, ok := e.GetVar(name); ok {
+		for _, tp := range entry.Types {
+			if _, ok := seen[tp.String()]; !ok {
+				seen[tp.String()] = struct{}{}
+				ret = append(ret, tp.String())
+			}
+		}
+	}
+
+	for _, cur := range e.envChain() {
+		for _, tp := range cur.VarTypeMemory.GetTypesOf(name) {
+			if _, ok := seen[tp]; !ok {
+				seen[tp] = struct{}{}
+				ret = append(ret, tp)
+			}
+		}
+	}
+
+	return ret
+}
+
+// HasType tells whether a var has the type directly or through the types which its types extend.
+func (e *Env) HasType(name string, tp parser.FcVarType) bool {
+	target := tp.String()
+	toVisit := e.GetVarTypes(name)
+	visited := map[string]struct{}{}
+
+	for len(toVisit) > 0 {
+		cur := toVisit[len(toVisit)-1]
+		toVisit = toVisit[:len(toVisit)-1]
+
+		if cur == target {
+			return true
+		}
+		if _, ok := visited[cur]; ok {
+			continue
+		}
+		visited[cur] = struct{}{}
+
+		if entry, ok := e.GetType(cur); ok {
+			toVisit = append(toVisit, entry.Extends...)
+		}
+	}
+
+	return false
+}
+
+// GetVarsOfType returns the vars which have the type directly, from the outermost env inwards.
+func (e *Env) GetVarsOfType(tp parser.FcVarType) []string {
+	ret := []string{}
+	seen := map[string]struct{}{}
+	for _, cur := range e.envChain() {
+		vars, _ := cur.VarTypeMemory.Get(tp)
+		for _, name := range vars {
+			if _, ok := seen[name]; !ok {
+				seen[name] = struct{}{}
+				ret = append(ret, name)
+			}
+		}
+	}
+	return ret
+}
+
+// getVarTypeFact recognizes x is T, which is parsed as $T(x), when x is a var and T is a declared type.
+func (e *Env) getVarTypeFact(fact parser.FactStmt) (string, parser.FcVarType, bool) {
+	funcFact, ok := fact.(*parser.FuncFactStmt)
+	if !ok || !funcFact.IsTrue {
+		return "", parser.FcVarType{}, false
+	}
+
+	fc, ok := funcFact.Fc.(*parser.FcFnRetValue)
+	if !ok || len(fc.TypeParamsVarParamsPairs) != 1 {
+		return "", parser.FcVarType{}, false
+	}
+
+	pair := fc.TypeParamsVarParamsPairs[0]
+	if len(pair.TypeParams) != 0 || len(pair.VarParams) != 1 {
+		return "", parser.FcVarType{}, false
+	}
+
+	name, ok := pair.VarParams[0].(parser.FcStr)
+	if !ok {
+		return "", parser.FcVarType{}, false
+	}
+
+	if _, ok := e.GetType(fc.FnName.Value); !ok {
+		return "", parser.FcVarType{}, false
+	}
+	if _, ok := e.GetVar(name.Value); !ok {
+		return "", parser.FcVarType{}, false
+	}
+
+	return name.Value, parser.FcVarType{PackageName: "", Value: parser.FcVarTypeStrValue(fc.FnName.Value)}, true
+}
+
+// newVarTypeFact is the fact x is T, printed as $T(x)
+func newVarTypeFact(name string, tp string) *parser.FuncFactStmt {
+	return &parser.FuncFactStmt{IsTrue: true, Fc: &parser.FcFnRetValue{FnName: parser.FcStr{Value: tp}, TypeParamsVarParamsPairs: []parser.TypeParamsAndParamsPair{{TypeParams: []parser.TypeVarStr{}, VarParams: []parser.Fc{parser.FcStr{Value: name}}}}}}
+}
+
+// typeDeclSource prints a type declared in TypeMemory as the statement which declares it
+func typeDeclSource(name string, entry *memory.TypeMemEntry) string {
+	if len(entry.Extends) == 0 {
+		return fmt.Sprintf("%s %s", parser.Keywords["type"], name)
+	}
+	return fmt.Sprintf("%s %s %s %s", parser.Keywords["type"], parser.Keywords["impl"], entry.Extends[0], name)
+}